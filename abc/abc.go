@@ -0,0 +1,212 @@
+// Package abc converts between ABC notation and MIDI files, for simple
+// single-voice melodies such as folk tunes.
+package abc
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultDivision = 480
+	DefaultVelocity = 80
+)
+
+var letterFifths = map[byte]int{
+	'F': -1,
+	'C': 0,
+	'G': 1,
+	'D': 2,
+	'A': 3,
+	'E': 4,
+	'B': 5,
+}
+
+// modeFifths is how far each mode's key signature lies from that of the
+// major key on the same tonic, in fifths.
+var modeFifths = map[string]int{
+	"":    0,
+	"maj": 0,
+	"ion": 0,
+	"m":   -3,
+	"min": -3,
+	"aeo": -3,
+	"dor": -2,
+	"phr": -4,
+	"lyd": 1,
+	"mix": -1,
+	"loc": -5,
+}
+
+const (
+	sharpOrder = "FCGDAEB"
+	flatOrder  = "BEADGCF"
+)
+
+// key is a parsed K: field.
+type key struct {
+	fifths int
+	minor  bool
+}
+
+func parseKey(s string) (key, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.EqualFold(s, "none") || s == "HP" || s == "Hp" {
+		return key{}, nil
+	}
+
+	letter := strings.ToUpper(s[:1])[0]
+	fifths, ok := letterFifths[letter]
+	if !ok {
+		return key{}, fmt.Errorf("invalid key %q", s)
+	}
+
+	rest := s[1:]
+	switch {
+	case strings.HasPrefix(rest, "#"):
+		fifths += 7
+		rest = rest[1:]
+	case strings.HasPrefix(rest, "b"):
+		fifths -= 7
+		rest = rest[1:]
+	}
+
+	fields := strings.Fields(rest)
+	mode := ""
+	if len(fields) > 0 && isLetter(fields[0][0]) && !strings.Contains(fields[0], "=") {
+		mode = strings.ToLower(fields[0])
+		if len(mode) > 3 {
+			mode = mode[:3]
+		}
+	}
+
+	offset, ok := modeFifths[mode]
+	if !ok {
+		return key{}, fmt.Errorf("invalid mode in key %q", s)
+	}
+
+	return key{
+		fifths: fifths + offset,
+		minor:  offset == -3,
+	}, nil
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// accidental returns the alteration in semitones that the key signature
+// applies to a note letter.
+func (k key) accidental(letter byte) int {
+	if k.fifths > 0 && strings.IndexByte(sharpOrder, letter) < k.fifths {
+		return 1
+	}
+	if k.fifths < 0 && strings.IndexByte(flatOrder, letter) < -k.fifths {
+		return -1
+	}
+	return 0
+}
+
+// meter is a parsed M: field; a zero numerator means free meter.
+type meter struct {
+	numerator   int
+	denominator int
+}
+
+func parseMeter(s string) (meter, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "" || strings.EqualFold(s, "none"):
+		return meter{}, nil
+	case s == "C":
+		return meter{4, 4}, nil
+	case s == "C|":
+		return meter{2, 2}, nil
+	}
+
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return meter{}, fmt.Errorf("invalid meter %q", s)
+	}
+
+	numerator := 0
+	for _, term := range strings.Split(strings.Trim(parts[0], "()"), "+") {
+		n, err := strconv.Atoi(strings.TrimSpace(term))
+		if err != nil || n <= 0 {
+			return meter{}, fmt.Errorf("invalid meter %q", s)
+		}
+		numerator += n
+	}
+
+	denominator, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || denominator <= 0 || denominator&(denominator-1) != 0 {
+		return meter{}, fmt.Errorf("invalid meter %q", s)
+	}
+
+	return meter{numerator, denominator}, nil
+}
+
+// barLength is the length of a bar in whole notes.
+func (m meter) barLength() *big.Rat {
+	if m.numerator == 0 {
+		return nil
+	}
+	return big.NewRat(int64(m.numerator), int64(m.denominator))
+}
+
+// parseFraction parses a length such as "1/8".
+func parseFraction(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || r.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid length %q", s)
+	}
+	return r, nil
+}
+
+// parseTempo parses a Q: field into micros per quarter note, given the
+// unit note length for the legacy form without a beat length.
+func parseTempo(s string, unit *big.Rat) (int64, error) {
+	for {
+		i := strings.Index(s, "\"")
+		if i < 0 {
+			break
+		}
+		j := strings.Index(s[i+1:], "\"")
+		if j < 0 {
+			return 0, fmt.Errorf("unterminated string in tempo %q", s)
+		}
+		s = s[:i] + s[i+1+j+1:]
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty tempo")
+	}
+
+	beat := unit
+	bpmString := s
+	if i := strings.Index(s, "="); i >= 0 {
+		beat = new(big.Rat)
+		for _, field := range strings.Fields(s[:i]) {
+			if field == "C" {
+				beat.Add(beat, big.NewRat(1, 4))
+				continue
+			}
+			r, err := parseFraction(field)
+			if err != nil {
+				return 0, fmt.Errorf("invalid tempo %q", s)
+			}
+			beat.Add(beat, r)
+		}
+		bpmString = strings.TrimSpace(s[i+1:])
+	}
+
+	bpm, err := strconv.ParseFloat(bpmString, 64)
+	if err != nil || bpm <= 0 || beat.Sign() <= 0 {
+		return 0, fmt.Errorf("invalid tempo %q", s)
+	}
+
+	beatQuarters, _ := new(big.Rat).Mul(beat, big.NewRat(4, 1)).Float64()
+	return int64(60e6/(bpm*beatQuarters) + 0.5), nil
+}
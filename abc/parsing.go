@@ -0,0 +1,832 @@
+package abc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/steinarvk/midi"
+)
+
+type elementKind int
+
+const (
+	noteElement elementKind = iota
+	barElement
+	metaElement
+)
+
+// element is a note, chord, rest, bar line or inline field of a tune,
+// with lengths measured in whole notes.
+type element struct {
+	kind elementKind
+
+	keys   []int
+	length *big.Rat
+	tie    bool
+
+	repeatStart bool
+	repeatEnd   bool
+	sectionEnd  bool
+	ending      int
+
+	meta midi.MetaEvent
+}
+
+type tuneParser struct {
+	unit  *big.Rat
+	meter meter
+	key   key
+
+	sawUnit bool
+
+	elements []element
+
+	// bodyStart is the first element after the header's meta events,
+	// where a repeat without a start sign goes back to.
+	bodyStart int
+
+	barAccidentals map[int]int
+
+	tupletRemaining int
+	tupletFactor    *big.Rat
+
+	brokenFactor *big.Rat
+}
+
+var noteOffsets = map[byte]int{
+	'C': 0,
+	'D': 2,
+	'E': 4,
+	'F': 5,
+	'G': 7,
+	'A': 9,
+	'B': 11,
+}
+
+// Parse reads the first tune of an ABC file.
+func Parse(r io.Reader) (*midi.File, error) {
+	tunes, err := ParseAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(tunes) == 0 {
+		return nil, errors.New("no tunes found")
+	}
+	return tunes[0], nil
+}
+
+// ParseAll reads every tune of an ABC file, each starting with an X: field.
+func ParseAll(r io.Reader) ([]*midi.File, error) {
+	scanner := bufio.NewScanner(r)
+
+	var rv []*midi.File
+	var lines []string
+	firstLine := 0
+	inTune := false
+
+	flush := func() error {
+		if !inTune {
+			return nil
+		}
+		f, err := parseTune(lines, firstLine)
+		if err != nil {
+			return err
+		}
+		rv = append(rv, f)
+		lines = nil
+		inTune = false
+		return nil
+	}
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, "X:") {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			inTune = true
+			firstLine = lineNo
+		}
+
+		if inTune {
+			lines = append(lines, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return rv, nil
+}
+
+func parseTune(lines []string, firstLine int) (*midi.File, error) {
+	p := &tuneParser{
+		unit:           big.NewRat(1, 8),
+		barAccidentals: map[int]int{},
+	}
+
+	var title string
+	var tempo int64
+	var tempoString string
+	inBody := false
+
+	for i, line := range lines {
+		lineNo := firstLine + i
+		wrap := func(err error) error {
+			return fmt.Errorf("line %d: %v", lineNo, err)
+		}
+
+		if j := strings.Index(line, "%"); j >= 0 {
+			line = line[:j]
+		}
+
+		if strings.TrimSpace(line) == "" {
+			if inBody {
+				break
+			}
+			continue
+		}
+
+		if isFieldLine(line) {
+			field, value := line[0], strings.TrimSpace(line[2:])
+
+			if !inBody {
+				switch field {
+				case 'T':
+					if title == "" {
+						title = value
+					}
+				case 'Q':
+					tempoString = value
+				case 'K':
+					if err := p.setField('K', value); err != nil {
+						return nil, wrap(err)
+					}
+					if !p.sawUnit {
+						p.unit = p.meter.defaultUnit()
+					}
+					if tempoString != "" {
+						var err error
+						if tempo, err = parseTempo(tempoString, p.unit); err != nil {
+							return nil, wrap(err)
+						}
+					}
+					p.startBody(title, tempo)
+					inBody = true
+				case 'M', 'L':
+					if err := p.setField(field, value); err != nil {
+						return nil, wrap(err)
+					}
+				}
+				continue
+			}
+
+			if err := p.inlineField(field, value); err != nil {
+				return nil, wrap(err)
+			}
+			continue
+		}
+
+		if !inBody {
+			return nil, wrap(errors.New("music before K: field"))
+		}
+
+		if err := p.parseLine(line); err != nil {
+			return nil, wrap(err)
+		}
+	}
+
+	if !inBody {
+		return nil, fmt.Errorf("line %d: tune without K: field", firstLine)
+	}
+
+	return render(expandRepeats(p.elements, p.bodyStart)), nil
+}
+
+func isFieldLine(line string) bool {
+	return len(line) >= 2 && line[1] == ':' && isLetter(line[0])
+}
+
+func (m meter) defaultUnit() *big.Rat {
+	if m.numerator != 0 && 4*m.numerator < 3*m.denominator {
+		return big.NewRat(1, 16)
+	}
+	return big.NewRat(1, 8)
+}
+
+func (p *tuneParser) setField(field byte, value string) error {
+	switch field {
+	case 'M':
+		m, err := parseMeter(value)
+		if err != nil {
+			return err
+		}
+		p.meter = m
+
+	case 'L':
+		unit, err := parseFraction(value)
+		if err != nil {
+			return err
+		}
+		p.unit = unit
+		p.sawUnit = true
+
+	case 'K':
+		k, err := parseKey(value)
+		if err != nil {
+			return err
+		}
+		p.key = k
+	}
+
+	return nil
+}
+
+func (p *tuneParser) startBody(title string, tempo int64) {
+	if title != "" {
+		p.addMeta(midi.MetaEvent{Type: midi.TrackName, Data: []byte(title)})
+	}
+	if p.meter.numerator != 0 {
+		p.addMeta(midi.NewTimeSignatureEvent(p.meter.numerator, p.meter.denominator))
+	}
+	p.addMeta(midi.NewKeySignatureEvent(p.key.fifths, p.key.minor))
+	if tempo != 0 {
+		p.addMeta(midi.NewTempoEvent(tempo))
+	}
+	p.bodyStart = len(p.elements)
+}
+
+func (p *tuneParser) addMeta(evt midi.MetaEvent) {
+	p.elements = append(p.elements, element{kind: metaElement, meta: evt})
+}
+
+// inlineField handles a field in the tune body, either on its own line or
+// in brackets such as [K:G].
+func (p *tuneParser) inlineField(field byte, value string) error {
+	switch field {
+	case 'K', 'M', 'L':
+		if err := p.setField(field, value); err != nil {
+			return err
+		}
+		switch field {
+		case 'K':
+			p.addMeta(midi.NewKeySignatureEvent(p.key.fifths, p.key.minor))
+		case 'M':
+			if p.meter.numerator != 0 {
+				p.addMeta(midi.NewTimeSignatureEvent(p.meter.numerator, p.meter.denominator))
+			}
+		}
+
+	case 'Q':
+		tempo, err := parseTempo(value, p.unit)
+		if err != nil {
+			return err
+		}
+		p.addMeta(midi.NewTempoEvent(tempo))
+	}
+
+	return nil
+}
+
+func (p *tuneParser) parseLine(line string) error {
+	i := 0
+	for i < len(line) {
+		c := line[i]
+		var err error
+
+		switch {
+		case c == ' ' || c == '\t' || c == '`' || c == '\\' || c == 'y' || c == '$' || c == ')':
+			i++
+
+		case c == '"':
+			i, err = skipPast(line, i+1, '"')
+
+		case c == '!' || c == '+':
+			i, err = skipPast(line, i+1, c)
+
+		case c == '{':
+			i, err = skipPast(line, i+1, '}')
+
+		case strings.IndexByte("~.HLMOPSTuv", c) >= 0:
+			i++
+
+		case c == '(':
+			i, err = p.parseTuplet(line, i+1)
+
+		case c == '-':
+			if err = p.tieLast(); err == nil {
+				i++
+			}
+
+		case c == '>' || c == '<':
+			i, err = p.parseBrokenRhythm(line, i)
+
+		case c == '[' && i+2 < len(line) && isLetter(line[i+1]) && line[i+2] == ':':
+			end := strings.IndexByte(line[i:], ']')
+			if end < 0 {
+				return fmt.Errorf("unterminated inline field at column %d", i+1)
+			}
+			err = p.inlineField(line[i+1], strings.TrimSpace(line[i+3:i+end]))
+			i += end + 1
+
+		case c == '[' && i+1 < len(line) && line[i+1] >= '1' && line[i+1] <= '9':
+			i, err = p.parseBar(line, i)
+
+		case c == '[' && i+1 < len(line) && line[i+1] != '|':
+			i, err = p.parseChord(line, i+1)
+
+		case c == '|' || c == ':' || c == '[' || c == ']':
+			i, err = p.parseBar(line, i)
+
+		case c == 'z' || c == 'x':
+			var mult *big.Rat
+			mult, i, err = parseLength(line, i+1)
+			if err == nil {
+				p.addNote(nil, mult)
+			}
+
+		case c == 'Z' || c == 'X':
+			i, err = p.parseMultiBarRest(line, i+1)
+
+		default:
+			var key int
+			var mult *big.Rat
+			key, mult, i, err = p.parseNote(line, i)
+			if err == nil {
+				p.addNote([]int{key}, mult)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func skipPast(line string, i int, terminator byte) (int, error) {
+	j := strings.IndexByte(line[i:], terminator)
+	if j < 0 {
+		return 0, fmt.Errorf("missing %q at column %d", terminator, i)
+	}
+	return i + j + 1, nil
+}
+
+// parseNote parses a note with its accidentals, octave marks and length.
+func (p *tuneParser) parseNote(line string, i int) (int, *big.Rat, int, error) {
+	start := i
+
+	explicit := false
+	accidental := 0
+	for i < len(line) && strings.IndexByte("^_=", line[i]) >= 0 {
+		explicit = true
+		switch line[i] {
+		case '^':
+			accidental++
+		case '_':
+			accidental--
+		}
+		i++
+	}
+
+	if i >= len(line) {
+		return 0, nil, 0, fmt.Errorf("unexpected end of line at column %d", start+1)
+	}
+
+	letter := line[i]
+	upper := strings.ToUpper(string(letter))[0]
+	offset, ok := noteOffsets[upper]
+	if !ok {
+		return 0, nil, 0, fmt.Errorf("unexpected %q at column %d", line[i], i+1)
+	}
+	i++
+
+	natural := 60 + offset
+	if letter != upper {
+		natural += 12
+	}
+	for i < len(line) && (line[i] == '\'' || line[i] == ',') {
+		if line[i] == '\'' {
+			natural += 12
+		} else {
+			natural -= 12
+		}
+		i++
+	}
+
+	if explicit {
+		p.barAccidentals[natural] = accidental
+	} else if acc, ok := p.barAccidentals[natural]; ok {
+		accidental = acc
+	} else {
+		accidental = p.key.accidental(upper)
+	}
+
+	mult, i, err := parseLength(line, i)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	key := natural + accidental
+	if key < 0 || key > 127 {
+		return 0, nil, 0, fmt.Errorf("note out of range at column %d", start+1)
+	}
+
+	return key, mult, i, nil
+}
+
+// parseLength parses a length multiplier such as "3", "/", "//", "3/2".
+func parseLength(line string, i int) (*big.Rat, int, error) {
+	num, den := int64(1), int64(1)
+
+	j := i
+	for j < len(line) && line[j] >= '0' && line[j] <= '9' {
+		j++
+	}
+	if j > i {
+		n, err := strconv.ParseInt(line[i:j], 10, 64)
+		if err != nil || n == 0 {
+			return nil, 0, fmt.Errorf("invalid length at column %d", i+1)
+		}
+		num = n
+	}
+	i = j
+
+	slashes := 0
+	for i < len(line) && line[i] == '/' {
+		slashes++
+		i++
+	}
+	if slashes > 0 {
+		j = i
+		for j < len(line) && line[j] >= '0' && line[j] <= '9' {
+			j++
+		}
+		if j > i {
+			n, err := strconv.ParseInt(line[i:j], 10, 64)
+			if err != nil || n == 0 {
+				return nil, 0, fmt.Errorf("invalid length at column %d", i+1)
+			}
+			den = n
+		} else {
+			den = 1 << uint(slashes)
+		}
+		i = j
+	}
+
+	return big.NewRat(num, den), i, nil
+}
+
+func (p *tuneParser) addNote(keys []int, mult *big.Rat) {
+	length := new(big.Rat).Mul(p.unit, mult)
+
+	if p.tupletRemaining > 0 {
+		length.Mul(length, p.tupletFactor)
+		p.tupletRemaining--
+	}
+
+	if p.brokenFactor != nil {
+		length.Mul(length, p.brokenFactor)
+		p.brokenFactor = nil
+	}
+
+	p.elements = append(p.elements, element{
+		kind:   noteElement,
+		keys:   keys,
+		length: length,
+	})
+}
+
+func (p *tuneParser) lastNote() *element {
+	if n := len(p.elements); n > 0 && p.elements[n-1].kind == noteElement {
+		return &p.elements[n-1]
+	}
+	return nil
+}
+
+func (p *tuneParser) tieLast() error {
+	e := p.lastNote()
+	if e == nil || len(e.keys) == 0 {
+		return errors.New("tie without preceding note")
+	}
+	e.tie = true
+	return nil
+}
+
+func (p *tuneParser) parseBrokenRhythm(line string, i int) (int, error) {
+	c := line[i]
+	n := 0
+	for i < len(line) && line[i] == c {
+		n++
+		i++
+	}
+
+	e := p.lastNote()
+	if e == nil {
+		return 0, fmt.Errorf("broken rhythm without preceding note at column %d", i)
+	}
+
+	short := big.NewRat(1, 1<<uint(n))
+	long := new(big.Rat).Sub(big.NewRat(2, 1), short)
+	if c == '<' {
+		short, long = long, short
+	}
+
+	e.length.Mul(e.length, long)
+	p.brokenFactor = short
+	return i, nil
+}
+
+func (p *tuneParser) parseTuplet(line string, i int) (int, error) {
+	if i >= len(line) || line[i] < '2' || line[i] > '9' {
+		// A slur, which does not affect playback.
+		return i, nil
+	}
+
+	var nums [3]int
+	for k := 0; k < 3; k++ {
+		j := i
+		for j < len(line) && line[j] >= '0' && line[j] <= '9' {
+			j++
+		}
+		if j > i {
+			nums[k], _ = strconv.Atoi(line[i:j])
+		}
+		i = j
+		if k < 2 && i < len(line) && line[i] == ':' {
+			i++
+			continue
+		}
+		break
+	}
+
+	n, q, r := nums[0], nums[1], nums[2]
+	if q == 0 {
+		q = p.defaultTupletTime(n)
+	}
+	if r == 0 {
+		r = n
+	}
+
+	p.tupletRemaining = r
+	p.tupletFactor = big.NewRat(int64(q), int64(n))
+	return i, nil
+}
+
+func (p *tuneParser) defaultTupletTime(n int) int {
+	compound := p.meter.numerator%3 == 0 && p.meter.numerator > 3
+	switch n {
+	case 2, 4, 8:
+		return 3
+	case 3, 6:
+		return 2
+	}
+	if compound {
+		return 3
+	}
+	return 2
+}
+
+func (p *tuneParser) parseChord(line string, i int) (int, error) {
+	var keys []int
+	var first *big.Rat
+	for {
+		for i < len(line) && (line[i] == ' ' || line[i] == '-') {
+			i++
+		}
+		if i >= len(line) {
+			return 0, errors.New("unterminated chord")
+		}
+		if line[i] == ']' {
+			i++
+			break
+		}
+		key, mult, j, err := p.parseNote(line, i)
+		if err != nil {
+			return 0, err
+		}
+		if first == nil {
+			first = mult
+		}
+		keys = append(keys, key)
+		i = j
+	}
+
+	if len(keys) == 0 {
+		return 0, fmt.Errorf("empty chord at column %d", i)
+	}
+
+	mult, i, err := parseLength(line, i)
+	if err != nil {
+		return 0, err
+	}
+
+	p.addNote(keys, new(big.Rat).Mul(first, mult))
+	return i, nil
+}
+
+func (p *tuneParser) parseMultiBarRest(line string, i int) (int, error) {
+	bars := int64(1)
+	j := i
+	for j < len(line) && line[j] >= '0' && line[j] <= '9' {
+		j++
+	}
+	if j > i {
+		bars, _ = strconv.ParseInt(line[i:j], 10, 64)
+	}
+
+	barLength := p.meter.barLength()
+	if barLength == nil {
+		return 0, errors.New("multi-bar rest without meter")
+	}
+
+	p.elements = append(p.elements, element{
+		kind:   noteElement,
+		length: new(big.Rat).Mul(barLength, big.NewRat(bars, 1)),
+	})
+	return j, nil
+}
+
+// parseBar parses bar lines such as "|", "||", "|]", "|:", ":|", "::",
+// "|1" and ":|2", and first and second ending markers such as "[1".
+func (p *tuneParser) parseBar(line string, i int) (int, error) {
+	start := i
+	e := element{kind: barElement}
+
+	for i < len(line) && line[i] == ':' {
+		e.repeatEnd = true
+		i++
+	}
+
+	body := ""
+	for i < len(line) {
+		c := line[i]
+		if c == '|' || c == ']' || (c == '[' && i+1 < len(line) && line[i+1] == '|') {
+			body += string(c)
+			i++
+			continue
+		}
+		break
+	}
+
+	for i < len(line) && line[i] == ':' {
+		e.repeatStart = true
+		i++
+	}
+
+	if strings.Contains(body, "||") || strings.Contains(body, "]") || strings.Contains(body, "[") {
+		e.sectionEnd = true
+	}
+
+	if i < len(line) && line[i] == '[' && i+1 < len(line) && line[i+1] >= '1' && line[i+1] <= '9' {
+		i++
+	}
+	if i < len(line) && line[i] >= '1' && line[i] <= '9' && (body != "" || start < i) {
+		e.ending = int(line[i] - '0')
+		i++
+		for i < len(line) && (line[i] == ',' || line[i] == '-' || (line[i] >= '1' && line[i] <= '9')) {
+			i++
+		}
+	}
+
+	if i == start {
+		return 0, fmt.Errorf("unexpected %q at column %d", line[i], i+1)
+	}
+
+	if body != "" || e.ending != 0 {
+		p.barAccidentals = map[int]int{}
+	}
+
+	p.elements = append(p.elements, e)
+	return i, nil
+}
+
+// expandRepeats unrolls repeated sections and first and second endings.
+// A repeat with no start sign goes back to the element at bodyStart.
+func expandRepeats(elements []element, bodyStart int) []element {
+	var rv []element
+	start := bodyStart
+	secondPass := false
+
+	for i := 0; i < len(elements); i++ {
+		e := elements[i]
+		if e.kind != barElement {
+			rv = append(rv, e)
+			continue
+		}
+
+		if e.ending == 1 && secondPass {
+			for j := i + 1; j < len(elements); j++ {
+				if elements[j].kind == barElement && elements[j].ending >= 2 {
+					i = j
+					start = j + 1
+					secondPass = false
+					break
+				}
+			}
+			continue
+		}
+
+		if e.repeatEnd {
+			if !secondPass {
+				secondPass = true
+				i = start - 1
+				continue
+			}
+			secondPass = false
+			start = i + 1
+		}
+
+		if e.repeatStart || e.sectionEnd {
+			start = i + 1
+			secondPass = false
+		}
+	}
+
+	return rv
+}
+
+func render(elements []element) *midi.File {
+	sw := midi.NewSimpleWriter(DefaultDivision)
+
+	ticks := func(r *big.Rat) int {
+		t := new(big.Rat).Mul(r, big.NewRat(4*DefaultDivision, 1))
+		q, m := new(big.Int).DivMod(t.Num(), t.Denom(), new(big.Int))
+		if 2*m.Int64() >= t.Denom().Int64() {
+			q.Add(q, big.NewInt(1))
+		}
+		return int(q.Int64())
+	}
+
+	type tiedNote struct {
+		start, end int
+	}
+	tied := map[int]tiedNote{}
+
+	flushTied := func(except []int) {
+		var keys []int
+		for key := range tied {
+			keep := false
+			for _, k := range except {
+				keep = keep || k == key
+			}
+			if !keep {
+				keys = append(keys, key)
+			}
+		}
+		sort.Ints(keys)
+		for _, key := range keys {
+			note := tied[key]
+			sw.NoteAt(note.start, key, DefaultVelocity, note.end-note.start)
+			delete(tied, key)
+		}
+	}
+
+	pos := new(big.Rat)
+	for _, e := range elements {
+		switch e.kind {
+		case metaElement:
+			sw.EventAt(ticks(pos), e.meta)
+
+		case noteElement:
+			flushTied(e.keys)
+
+			start := ticks(pos)
+			pos.Add(pos, e.length)
+			end := ticks(pos)
+
+			for _, key := range e.keys {
+				noteStart := start
+				if prev, ok := tied[key]; ok {
+					noteStart = prev.start
+					delete(tied, key)
+				}
+				if e.tie {
+					tied[key] = tiedNote{noteStart, end}
+					continue
+				}
+				sw.NoteAt(noteStart, key, DefaultVelocity, end-noteStart)
+			}
+		}
+	}
+
+	flushTied(nil)
+	sw.EventAt(ticks(pos), midi.MetaEvent{Type: midi.EndOfTrack})
+
+	return sw.File()
+}
@@ -0,0 +1,265 @@
+package abc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/steinarvk/midi"
+)
+
+type testNote struct {
+	start, key, duration int
+}
+
+// notesOf lists the notes of a track, in units of eighth notes.
+func notesOf(t *testing.T, trk *midi.Track) []testNote {
+	const eighth = DefaultDivision / 2
+
+	var rv []testNote
+	started := map[int]int{}
+	now := 0
+	for _, evt := range trk.Events {
+		switch v := evt.(type) {
+		case midi.TimeDeltaEvent:
+			now += int(v)
+		case midi.MIDIEvent:
+			switch v.Type {
+			case midi.NoteOn:
+				started[v.Key] = now
+			case midi.NoteOff:
+				start := started[v.Key]
+				if start%eighth != 0 || now%eighth != 0 {
+					t.Errorf("note %d at %d-%d is off the eighth-note grid", v.Key, start, now)
+				}
+				rv = append(rv, testNote{start / eighth, v.Key, (now - start) / eighth})
+			}
+		}
+	}
+	return rv
+}
+
+func metaEventsOf(trk *midi.Track) []midi.MetaEvent {
+	var rv []midi.MetaEvent
+	for _, evt := range trk.Events {
+		if v, ok := evt.(midi.MetaEvent); ok {
+			rv = append(rv, v)
+		}
+	}
+	return rv
+}
+
+func TestParseHeader(t *testing.T) {
+	f, err := Parse(strings.NewReader(`X:1
+T:The Kesh
+T:The Kesh Jig
+M:6/8
+L:1/8
+Q:3/8=120
+K:G
+GAG GAB|
+`))
+	if err != nil {
+		t.Fatalf("Parse() = err: %v", err)
+	}
+
+	want := []midi.MetaEvent{
+		{Type: midi.TrackName, Data: []byte("The Kesh")},
+		midi.NewTimeSignatureEvent(6, 8),
+		midi.NewKeySignatureEvent(1, false),
+		midi.NewTempoEvent(333333),
+		{Type: midi.EndOfTrack},
+	}
+	if got := metaEventsOf(f.Tracks[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("meta events = %v want %v", got, want)
+	}
+}
+
+func TestParseNotes(t *testing.T) {
+	testcases := []struct {
+		abc  string
+		want []testNote
+	}{
+		{
+			"K:C\nC D E F | G A B c | c' C, |\n",
+			[]testNote{
+				{0, 60, 1}, {1, 62, 1}, {2, 64, 1}, {3, 65, 1},
+				{4, 67, 1}, {5, 69, 1}, {6, 71, 1}, {7, 72, 1},
+				{8, 84, 1}, {9, 48, 1},
+			},
+		},
+		{
+			"K:D\nF c =F ^G | F _B2 z2 |\n",
+			[]testNote{
+				{0, 66, 1}, {1, 73, 1}, {2, 65, 1}, {3, 68, 1},
+				{4, 66, 1}, {5, 70, 2},
+			},
+		},
+		{
+			"K:F\nB =B B | B |\n",
+			[]testNote{{0, 70, 1}, {1, 71, 1}, {2, 71, 1}, {3, 70, 1}},
+		},
+		{
+			"L:1/4\nK:C\nC2 D/2E/2 F3/2G/ | A4- | A2 [CEG]2 |\n",
+			[]testNote{
+				{0, 60, 4}, {4, 62, 1}, {5, 64, 1}, {6, 65, 3}, {9, 67, 1},
+				{10, 69, 12}, {22, 60, 4}, {22, 64, 4}, {22, 67, 4},
+			},
+		},
+		{
+			"L:1/4\nK:C\nC>D E<F | C2>>D2 |\n",
+			[]testNote{
+				{0, 60, 3}, {3, 62, 1}, {4, 64, 1}, {5, 65, 3},
+				{8, 60, 7}, {15, 62, 1},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		f, err := Parse(strings.NewReader("X:1\nM:4/4\n" + testcase.abc))
+		if err != nil {
+			t.Errorf("Parse(%q) = err: %v", testcase.abc, err)
+			continue
+		}
+		if got := notesOf(t, f.Tracks[0]); !reflect.DeepEqual(got, testcase.want) {
+			t.Errorf("Parse(%q) notes = %v want %v", testcase.abc, got, testcase.want)
+		}
+	}
+}
+
+func TestParseTriplets(t *testing.T) {
+	f, err := Parse(strings.NewReader("X:1\nM:2/4\nL:1/8\nK:C\n(3CDE F |\n"))
+	if err != nil {
+		t.Fatalf("Parse() = err: %v", err)
+	}
+
+	var starts []int
+	now := 0
+	for _, evt := range f.Tracks[0].Events {
+		switch v := evt.(type) {
+		case midi.TimeDeltaEvent:
+			now += int(v)
+		case midi.MIDIEvent:
+			if v.Type == midi.NoteOn {
+				starts = append(starts, now)
+			}
+		}
+	}
+
+	want := []int{0, 160, 320, 480}
+	if !reflect.DeepEqual(starts, want) {
+		t.Errorf("triplet note starts = %v want %v", starts, want)
+	}
+}
+
+func TestParseRepeats(t *testing.T) {
+	testcases := []struct {
+		abc  string
+		want []int
+	}{
+		{"|: C D :| E |\n", []int{60, 62, 60, 62, 64}},
+		{"C |: D :| E :: F :| G |]\n", []int{60, 62, 62, 64, 64, 65, 65, 67}},
+		{"|: C |1 D :|2 E |]\n", []int{60, 62, 60, 64}},
+		{"|: C [1 D :| [2 E || F :|\n", []int{60, 62, 60, 64, 65, 65}},
+	}
+
+	for _, testcase := range testcases {
+		f, err := Parse(strings.NewReader("X:1\nK:C\n" + testcase.abc))
+		if err != nil {
+			t.Errorf("Parse(%q) = err: %v", testcase.abc, err)
+			continue
+		}
+
+		var keys []int
+		for _, note := range notesOf(t, f.Tracks[0]) {
+			keys = append(keys, note.key)
+		}
+		if !reflect.DeepEqual(keys, testcase.want) {
+			t.Errorf("Parse(%q) keys = %v want %v", testcase.abc, keys, testcase.want)
+		}
+	}
+}
+
+func TestParseRepeatWithoutStart(t *testing.T) {
+	f, err := Parse(strings.NewReader("X:1\nT:Tune\nM:4/4\nQ:1/4=120\nK:G\nGABc :|\n"))
+	if err != nil {
+		t.Fatalf("Parse() = err: %v", err)
+	}
+
+	want := []midi.MetaEvent{
+		{Type: midi.TrackName, Data: []byte("Tune")},
+		midi.NewTimeSignatureEvent(4, 4),
+		midi.NewKeySignatureEvent(1, false),
+		midi.NewTempoEvent(500000),
+		{Type: midi.EndOfTrack},
+	}
+	if got := metaEventsOf(f.Tracks[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("meta events = %v want %v", got, want)
+	}
+
+	var keys []int
+	for _, note := range notesOf(t, f.Tracks[0]) {
+		keys = append(keys, note.key)
+	}
+	if want := []int{67, 69, 71, 72, 67, 69, 71, 72}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v want %v", keys, want)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	testcases := []struct {
+		field  string
+		fifths int
+		minor  bool
+	}{
+		{"C", 0, false},
+		{"G", 1, false},
+		{"Bb", -2, false},
+		{"F#", 6, false},
+		{"Am", 0, true},
+		{"Emin", 1, true},
+		{"D dorian", 0, false},
+		{"A mixolydian", 2, false},
+		{"E phr", 0, false},
+		{"F lydian", 0, false},
+		{"none", 0, false},
+	}
+
+	for _, testcase := range testcases {
+		k, err := parseKey(testcase.field)
+		if err != nil {
+			t.Errorf("parseKey(%q) = err: %v", testcase.field, err)
+			continue
+		}
+		if k.fifths != testcase.fifths || k.minor != testcase.minor {
+			t.Errorf("parseKey(%q) = %+v want fifths=%d minor=%v", testcase.field, k, testcase.fifths, testcase.minor)
+		}
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	tunes, err := ParseAll(strings.NewReader("% collection\nX:1\nK:C\nCDE|\n\nX:2\nT:Two\nK:G\nGAB|\n"))
+	if err != nil {
+		t.Fatalf("ParseAll() = err: %v", err)
+	}
+	if len(tunes) != 2 {
+		t.Fatalf("ParseAll() returned %d tune(s) want 2", len(tunes))
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testcases := []string{
+		"X:1\nCDE|\n",
+		"X:1\nK:C\nCDE Q|\n",
+		"X:1\nK:Q\nCDE|\n",
+		"X:1\nM:7/9\nK:C\nCDE|\n",
+		"X:1\nK:C\n[CEG\n",
+		"X:1\nK:C\n- C\n",
+	}
+
+	for _, testcase := range testcases {
+		if f, err := Parse(strings.NewReader(testcase)); err == nil {
+			t.Errorf("Parse(%q) = %v want error", testcase, f)
+		}
+	}
+}
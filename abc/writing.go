@@ -0,0 +1,208 @@
+package abc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/steinarvk/midi"
+	"github.com/steinarvk/midi/score"
+)
+
+const barsPerLine = 4
+
+// Write writes one track of a file as an ABC tune. The track should be
+// quantized, with every note and rest a whole number of 64th notes long.
+// The unit note length is the power-of-two note value nearest to the most
+// common note length; notes are spelled according to the key signature,
+// bar lines follow the time signature, and notes crossing a bar line are
+// split and tied.
+func Write(w io.Writer, f *midi.File, trackNo int) error {
+	s, err := score.Layout(f, trackNo)
+	if err != nil {
+		return err
+	}
+
+	unit := chooseUnit(s)
+	wholeTicks := 4 * s.Division
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "X:1\n")
+	if s.Title != "" {
+		fmt.Fprintf(bw, "T:%s\n", s.Title)
+	}
+	fmt.Fprintf(bw, "M:%d/%d\n", s.Bars[0].Meter.Numerator, s.Bars[0].Meter.Denominator)
+	fmt.Fprintf(bw, "L:1/%d\n", unit)
+	if s.Tempo > 0 {
+		fmt.Fprintf(bw, "Q:1/4=%d\n", int(math.Floor(60e6/float64(s.Tempo)+0.5)))
+	}
+	fmt.Fprintf(bw, "K:%s\n", keyName(s.Bars[0].Key))
+
+	for i, bar := range s.Bars {
+		if i > 0 && bar.MeterChanged {
+			fmt.Fprintf(bw, "[M:%d/%d] ", bar.Meter.Numerator, bar.Meter.Denominator)
+		}
+		if i > 0 && bar.KeyChanged {
+			fmt.Fprintf(bw, "[K:%s] ", keyName(bar.Key))
+		}
+
+		accidentals := map[string]int{}
+		var tokens []string
+		for _, item := range bar.Items {
+			length := big.NewRat(item.Duration*int64(unit), wholeTicks)
+			if !isPowerOfTwo(length.Denom().Int64()) || length.Denom().Int64() > 64/int64(unit) {
+				return fmt.Errorf("%s at tick %d is not quantized", describe(item), item.Start)
+			}
+			tokens = append(tokens, formatItem(item, bar.Key, accidentals, length))
+		}
+
+		bw.WriteString(strings.Join(tokens, " "))
+		switch {
+		case i == len(s.Bars)-1:
+			bw.WriteString(" |]\n")
+		case (i+1)%barsPerLine == 0:
+			bw.WriteString(" |\n")
+		default:
+			bw.WriteString(" | ")
+		}
+	}
+
+	return bw.Flush()
+}
+
+func describe(item score.Item) string {
+	if item.IsRest() {
+		return "rest"
+	}
+	return fmt.Sprintf("note %v", item.Keys)
+}
+
+func isPowerOfTwo(n int64) bool {
+	return n > 0 && n&(n-1) == 0
+}
+
+// chooseUnit returns the denominator of the unit note length.
+func chooseUnit(s *score.Score) int {
+	counts := map[int64]int{}
+	var best int64
+	for _, bar := range s.Bars {
+		for _, item := range bar.Items {
+			if item.IsRest() || item.TiedFromPrevious || item.TiedToNext {
+				continue
+			}
+			counts[item.Duration]++
+			if c := counts[item.Duration]; c > counts[best] || (c == counts[best] && item.Duration < best) {
+				best = item.Duration
+			}
+		}
+	}
+
+	if best == 0 {
+		return 8
+	}
+
+	wholes := float64(best) / float64(4*s.Division)
+	unit := 1 << uint(math.Floor(-math.Log2(wholes)+0.5))
+	switch {
+	case unit < 4:
+		unit = 4
+	case unit > 32:
+		unit = 32
+	}
+	return unit
+}
+
+func keyName(k score.Key) string {
+	letter, alter := k.Tonic()
+	rv := string(letter)
+	switch alter {
+	case 1:
+		rv += "#"
+	case -1:
+		rv += "b"
+	}
+	if k.Minor {
+		rv += "m"
+	}
+	return rv
+}
+
+func formatItem(item score.Item, k score.Key, accidentals map[string]int, length *big.Rat) string {
+	var sb strings.Builder
+
+	if item.IsRest() {
+		sb.WriteString("z")
+	} else {
+		if len(item.Keys) > 1 {
+			sb.WriteString("[")
+		}
+		for _, key := range item.Keys {
+			sb.WriteString(formatPitch(key, k, accidentals))
+		}
+		if len(item.Keys) > 1 {
+			sb.WriteString("]")
+		}
+	}
+
+	sb.WriteString(formatLength(length))
+
+	if item.TiedToNext {
+		sb.WriteString("-")
+	}
+
+	return sb.String()
+}
+
+// formatPitch spells a key, writing an accidental only where the key
+// signature and earlier accidentals in the bar do not already imply it.
+func formatPitch(key int, k score.Key, accidentals map[string]int) string {
+	letter, alter, octave := k.Spell(key)
+
+	var name string
+	switch {
+	case octave >= 5:
+		name = strings.ToLower(string(letter)) + strings.Repeat("'", octave-5)
+	default:
+		name = string(letter) + strings.Repeat(",", 4-octave)
+	}
+
+	implied, ok := accidentals[name]
+	if !ok {
+		implied = k.Accidental(letter)
+	}
+	if alter == implied {
+		return name
+	}
+	accidentals[name] = alter
+
+	switch alter {
+	case 2:
+		return "^^" + name
+	case 1:
+		return "^" + name
+	case -1:
+		return "_" + name
+	case -2:
+		return "__" + name
+	}
+	return "=" + name
+}
+
+func formatLength(length *big.Rat) string {
+	num, den := length.Num().Int64(), length.Denom().Int64()
+	switch {
+	case den == 1 && num == 1:
+		return ""
+	case den == 1:
+		return fmt.Sprintf("%d", num)
+	case num == 1 && den == 2:
+		return "/"
+	case num == 1:
+		return fmt.Sprintf("/%d", den)
+	}
+	return fmt.Sprintf("%d/%d", num, den)
+}
@@ -0,0 +1,73 @@
+package abc
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/steinarvk/midi"
+)
+
+func TestWrite(t *testing.T) {
+	sw := midi.NewSimpleWriter(480)
+	sw.Event(midi.MetaEvent{Type: midi.TrackName, Data: []byte("Example")})
+	sw.Event(midi.NewTimeSignatureEvent(3, 4))
+	sw.Event(midi.NewKeySignatureEvent(1, false))
+	sw.Event(midi.NewTempoEvent(500000))
+	for _, key := range []int{67, 66, 65, 65} {
+		sw.Play([]int{key}, 80, 240)
+	}
+	sw.Play([]int{72}, 80, 720)
+	sw.Play([]int{74}, 80, 480)
+	sw.TimeDelta(240)
+	sw.Play([]int{55, 59, 62}, 80, 120)
+	sw.Play([]int{43}, 80, 120)
+
+	buf := bytes.NewBuffer(nil)
+	if err := Write(buf, sw.File(), 0); err != nil {
+		t.Fatalf("Write() = err: %v", err)
+	}
+
+	want := `X:1
+T:Example
+M:3/4
+L:1/8
+Q:1/4=120
+K:G
+G F =F F c2- | c d2 z [G,B,D]/ G,,/ |]
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Write() = %s want %s", got, want)
+	}
+}
+
+func TestWriteRoundtrip(t *testing.T) {
+	const tune = `X:1
+T:Roundtrip
+M:4/4
+L:1/8
+K:Bb
+B,2 C/D/E F2 B2 | d4- d2 _e =e | f8- | f2 z2 [Bdf]4 |
+[K:D] ^C2 D2 =f2 A,2 | [M:2/4] a4 |]
+`
+
+	f, err := Parse(strings.NewReader(tune))
+	if err != nil {
+		t.Fatalf("Parse() = err: %v", err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := Write(buf, f, 0); err != nil {
+		t.Fatalf("Write() = err: %v", err)
+	}
+
+	reparsed, err := Parse(buf)
+	if err != nil {
+		t.Fatalf("Parse(Write()) = err: %v", err)
+	}
+
+	if got, want := reparsed.Tracks[0].Notes(), f.Tracks[0].Notes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse(Write(f)) notes = %v want %v", got, want)
+	}
+}
@@ -0,0 +1,144 @@
+// Package chordchart writes the chords of a MIDI file as a text chord
+// chart with bar lines, such as
+//
+//	| 4/4 C | Am | F / G7 / | C ||
+package chordchart
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/steinarvk/midi"
+	"github.com/steinarvk/midi/harmony"
+	"github.com/steinarvk/midi/score"
+)
+
+const barsPerLine = 4
+
+type Options struct {
+	// Tracks lists the tracks to take chords from, such as those of the
+	// accompaniment. All tracks are used if it is empty.
+	Tracks []int
+
+	// PerBeat names a chord on every beat rather than one for every bar.
+	// A chord continuing from the previous beat is written as "/".
+	PerBeat bool
+}
+
+// Write recognizes the chords of a file and writes them as a chart. Bar
+// lines follow the file's time signatures, and the meter is written at the
+// start of the first bar and of every bar where it changes. Bars or beats
+// in which nothing sounds are written as "N.C.".
+func Write(w io.Writer, f *midi.File, opts Options) error {
+	tracks := opts.Tracks
+	if len(tracks) == 0 {
+		for i := range f.Tracks {
+			tracks = append(tracks, i)
+		}
+	}
+
+	var notes []midi.Note
+	var end int64
+	for _, trackNo := range tracks {
+		if trackNo < 0 || trackNo >= len(f.Tracks) {
+			return fmt.Errorf("no such track: %d (there are %d tracks)", trackNo, len(f.Tracks))
+		}
+		for _, n := range f.Tracks[trackNo].Notes() {
+			notes = append(notes, n)
+			if n.End() > end {
+				end = n.End()
+			}
+		}
+	}
+	if len(notes) == 0 {
+		return errors.New("no notes to write")
+	}
+
+	bars, err := score.Bars(f, end)
+	if err != nil {
+		return err
+	}
+
+	var cells []string
+	for _, bar := range bars {
+		var tokens []string
+		if bar.MeterChanged {
+			tokens = append(tokens, fmt.Sprintf("%d/%d", bar.Meter.Numerator, bar.Meter.Denominator))
+		}
+
+		beat := bar.Length
+		if opts.PerBeat {
+			beat = beatLength(int64(f.Header.Division), bar.Meter)
+		}
+		if beat < 1 {
+			// Beats shorter than a tick, at tiny divisions, cannot be
+			// told apart, so the bar gets one chord.
+			beat = bar.Length
+		}
+
+		previous := ""
+		for start := bar.Start; start < bar.Start+bar.Length; start += beat {
+			name := "N.C."
+			if c, ok := harmony.RecognizeChord(notes, start, minInt64(start+beat, bar.Start+bar.Length)); ok {
+				name = c.String()
+			}
+			if name == previous {
+				tokens = append(tokens, "/")
+			} else {
+				tokens = append(tokens, name)
+			}
+			previous = name
+		}
+
+		cells = append(cells, strings.Join(tokens, " "))
+	}
+
+	bw := bufio.NewWriter(w)
+	if title := title(f); title != "" {
+		fmt.Fprintf(bw, "%s\n\n", title)
+	}
+	for i := 0; i < len(cells); i += barsPerLine {
+		j := i + barsPerLine
+		if j > len(cells) {
+			j = len(cells)
+		}
+		ending := " |"
+		if j == len(cells) {
+			ending = " ||"
+		}
+		fmt.Fprintf(bw, "| %s%s\n", strings.Join(cells[i:j], " | "), ending)
+	}
+	return bw.Flush()
+}
+
+// beatLength is a quarter note in simple meters such as 4/4, and a dotted
+// quarter note in compound meters such as 6/8.
+func beatLength(division int64, m score.Meter) int64 {
+	beat := 4 * division / int64(m.Denominator)
+	if m.Denominator == 8 && m.Numerator > 3 && m.Numerator%3 == 0 {
+		beat *= 3
+	}
+	return beat
+}
+
+func title(f *midi.File) string {
+	if len(f.Tracks) == 0 {
+		return ""
+	}
+	for _, evt := range f.Tracks[0].Events {
+		if meta, ok := evt.(midi.MetaEvent); ok && meta.Type == midi.TrackName {
+			return string(meta.Data)
+		}
+	}
+	return ""
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
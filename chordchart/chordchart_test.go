@@ -0,0 +1,77 @@
+package chordchart
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/steinarvk/midi"
+)
+
+func testFile() *midi.File {
+	sw := midi.NewSimpleWriter(480)
+	sw.Event(midi.MetaEvent{Type: midi.TrackName, Data: []byte("Changes")})
+	sw.Play([]int{48, 64, 67}, 80, 1920)
+	sw.Play([]int{45, 60, 64}, 80, 1920)
+	sw.Play([]int{41, 60, 65, 69}, 80, 1440)
+	sw.Play([]int{43, 59, 62, 65}, 80, 480)
+	sw.TimeDelta(1920)
+	sw.Event(midi.NewTimeSignatureEvent(3, 4))
+	sw.Play([]int{50, 62, 65, 69}, 80, 1440)
+	sw.Play([]int{52, 60, 67}, 80, 1440)
+	return sw.File()
+}
+
+func TestWrite(t *testing.T) {
+	testcases := []struct {
+		opts Options
+		want string
+	}{
+		{
+			Options{},
+			`Changes
+
+| 4/4 C | Am | F | N.C. |
+| 3/4 Dm | C/E ||
+`,
+		},
+		{
+			Options{PerBeat: true, Tracks: []int{0}},
+			`Changes
+
+| 4/4 C / / / | Am / / / | F / / G7 | N.C. / / / |
+| 3/4 Dm / / | C/E / / ||
+`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		buf := bytes.NewBuffer(nil)
+		if err := Write(buf, testFile(), testcase.opts); err != nil {
+			t.Errorf("Write(%+v) = err: %v", testcase.opts, err)
+			continue
+		}
+		if got := buf.String(); got != testcase.want {
+			t.Errorf("Write(%+v) = %s want %s", testcase.opts, got, testcase.want)
+		}
+	}
+
+	if err := Write(bytes.NewBuffer(nil), testFile(), Options{Tracks: []int{3}}); err == nil {
+		t.Errorf("Write() with a missing track = nil want error")
+	}
+}
+
+func TestWriteBeatsShorterThanATick(t *testing.T) {
+	sw := midi.NewSimpleWriter(1)
+	sw.Event(midi.NewTimeSignatureEvent(4, 8))
+	sw.Play([]int{48, 64, 67}, 80, 2)
+	sw.Play([]int{45, 60, 64}, 80, 2)
+
+	want := "| 4/8 C | Am ||\n"
+	buf := bytes.NewBuffer(nil)
+	if err := Write(buf, sw.File(), Options{PerBeat: true}); err != nil {
+		t.Fatalf("Write() = err: %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("Write() = %s want %s", got, want)
+	}
+}
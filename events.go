@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+
+	"github.com/steinarvk/midi/pitch"
 )
 
 type TimeDeltaEvent int64
@@ -14,7 +16,12 @@ type MetaEvent struct {
 }
 
 const (
-	SetTempo byte = 0x51
+	TrackName     byte = 0x03
+	LyricText     byte = 0x05
+	EndOfTrack    byte = 0x2F
+	SetTempo      byte = 0x51
+	TimeSignature byte = 0x58
+	KeySignature  byte = 0x59
 )
 
 const (
@@ -25,7 +32,7 @@ const (
 // GetTempo retrieves the tempo in micros per quarter-note if this
 // is a tempo-change event.
 func (e MetaEvent) GetTempo() (int64, bool) {
-	if e.Type == SetTempo || len(e.Data) != 3 {
+	if e.Type != SetTempo || len(e.Data) != 3 {
 		return 0, false
 	}
 
@@ -36,6 +43,60 @@ func (e MetaEvent) GetTempo() (int64, bool) {
 	return rv, true
 }
 
+// NewTempoEvent creates a tempo-change event, in micros per quarter-note.
+func NewTempoEvent(microsPerBeat int64) MetaEvent {
+	return MetaEvent{
+		Type: SetTempo,
+		Data: []byte{byte(microsPerBeat >> 16), byte(microsPerBeat >> 8), byte(microsPerBeat)},
+	}
+}
+
+// GetTimeSignature retrieves the numerator and denominator if this is
+// a time-signature event.
+func (e MetaEvent) GetTimeSignature() (int, int, bool) {
+	if e.Type != TimeSignature || len(e.Data) != 4 || e.Data[1] > 30 {
+		return 0, 0, false
+	}
+
+	return int(e.Data[0]), 1 << e.Data[1], true
+}
+
+// NewTimeSignatureEvent creates a time-signature event. The denominator
+// must be a power of two.
+func NewTimeSignatureEvent(numerator, denominator int) MetaEvent {
+	var log2 byte
+	for (1 << log2) < denominator {
+		log2++
+	}
+	return MetaEvent{
+		Type: TimeSignature,
+		Data: []byte{byte(numerator), log2, 24, 8},
+	}
+}
+
+// GetKeySignature retrieves the number of sharps (negative for flats)
+// and whether the key is minor, if this is a key-signature event.
+func (e MetaEvent) GetKeySignature() (int, bool, bool) {
+	if e.Type != KeySignature || len(e.Data) != 2 {
+		return 0, false, false
+	}
+
+	return int(int8(e.Data[0])), e.Data[1] == 1, true
+}
+
+// NewKeySignatureEvent creates a key-signature event from the number of
+// sharps (negative for flats).
+func NewKeySignatureEvent(sharps int, minor bool) MetaEvent {
+	var mode byte
+	if minor {
+		mode = 1
+	}
+	return MetaEvent{
+		Type: KeySignature,
+		Data: []byte{byte(int8(sharps)), mode},
+	}
+}
+
 type Event interface {
 	EncodeMIDI() ([]byte, error)
 }
@@ -181,6 +242,36 @@ func presentEvent(evt event) (Event, error) {
 	}
 }
 
+// NewMIDIEvent decodes a channel message from its status byte and data
+// bytes, exactly as if it had been read from a file.
+func NewMIDIEvent(status byte, data []byte) (MIDIEvent, error) {
+	if status&0x80 == 0 || status >= 0xF0 {
+		return MIDIEvent{}, fmt.Errorf("%02x is not a channel message status byte", status)
+	}
+
+	spec := midiEventSpecs[int(status>>4)]
+	if len(data) != spec.dataLen {
+		return MIDIEvent{}, fmt.Errorf("%02x: want length %d, got %d (%v)", status, spec.dataLen, len(data), data)
+	}
+
+	for _, b := range data {
+		if b&0x80 != 0 {
+			return MIDIEvent{}, fmt.Errorf("%02x: data byte %02x out of range", status, b)
+		}
+	}
+
+	evt, err := presentEvent(event{
+		kind:     midiEvent,
+		typeByte: status,
+		data:     data,
+	})
+	if err != nil {
+		return MIDIEvent{}, err
+	}
+
+	return evt.(MIDIEvent), nil
+}
+
 func (e TimeDeltaEvent) String() string {
 	return fmt.Sprintf("TimeDelta %d", int(e))
 }
@@ -189,14 +280,39 @@ func (e SysexEvent) String() string {
 	return fmt.Sprintf("SysEx %02x", []byte(e))
 }
 
+// MetaEventName returns the name of a meta event type, such as "TrackName".
+func MetaEventName(metaType byte) (string, bool) {
+	name, ok := metaEventNames[int(metaType)]
+	return name, ok
+}
+
+// MetaEventType looks up a meta event type by the name MetaEventName gives it.
+func MetaEventType(name string) (byte, bool) {
+	for k, v := range metaEventNames {
+		if v == name {
+			return byte(k), true
+		}
+	}
+	return 0, false
+}
+
+// IsText checks whether the event's data is text, such as a track name
+// or a lyric.
+func (e MetaEvent) IsText() bool {
+	name, ok := metaEventNames[int(e.Type)]
+	if !ok {
+		return false
+	}
+	return strings.HasSuffix(name, "Text") || strings.HasSuffix(name, "Name") || strings.HasPrefix(name, "Text") || name == "CopyrightNotice" || name == "CuePoint"
+}
+
 func (e MetaEvent) String() string {
 	name, ok := metaEventNames[int(e.Type)]
 	if !ok {
 		name = fmt.Sprintf("Unknown:%02x", e.Type)
 	}
-	isText := strings.HasSuffix(name, "Text") || strings.HasSuffix(name, "Name") || strings.HasPrefix(name, "Text")
 
-	if isText {
+	if e.IsText() {
 		return fmt.Sprintf("Meta %s %q", name, string(e.Data))
 	}
 
@@ -208,10 +324,10 @@ func (e MIDIEvent) String() string {
 
 	switch e.Type {
 	case NoteOn:
-		return prefix + fmt.Sprintf("NoteOn k=%02x v=%02x", e.Key, e.Velocity)
+		return prefix + fmt.Sprintf("NoteOn k=%s v=%02x", pitch.Name(e.Key), e.Velocity)
 
 	case NoteOff:
-		return prefix + fmt.Sprintf("NoteOff k=%02x v=%02x", e.Key, e.Velocity)
+		return prefix + fmt.Sprintf("NoteOff k=%s v=%02x", pitch.Name(e.Key), e.Velocity)
 
 	default:
 		spec, present := midiEventSpecs[int(e.Type>>4)]
@@ -221,24 +337,49 @@ func (e MIDIEvent) String() string {
 		} else {
 			desc = fmt.Sprintf("Unknown:%02x", e.Type)
 		}
-		return prefix + fmt.Sprintf("%s % 02x", desc, e.RawData)
+		return prefix + fmt.Sprintf("%s % 02x", desc, e.Data())
 	}
 }
 
+// Status returns the type nibble of the event's status byte as it was
+// read, so that a NoteOn with zero velocity can be told from a NoteOff.
+func (e MIDIEvent) Status() MIDIEventType {
+	if e.RawType != 0 {
+		return MIDIEventType(e.RawType & 0xf0)
+	}
+	return e.Type
+}
+
+// Data returns the event's data bytes, recovering them from the decoded
+// fields for events constructed in code. It returns nil for pitch bends
+// without raw data.
+func (e MIDIEvent) Data() []byte {
+	if e.RawData != nil {
+		return e.RawData
+	}
+
+	switch e.Type {
+	case NoteOn, NoteOff, Aftertouch:
+		return []byte{byte(e.Key), byte(e.Velocity)}
+	case ControllerChange:
+		return []byte{byte(e.ControllerNumber), byte(e.ControllerValue)}
+	case ProgramChange:
+		return []byte{byte(e.ProgramNumber)}
+	case ChannelPressure:
+		return []byte{byte(e.Velocity)}
+	}
+
+	return nil
+}
+
 func (e MIDIEvent) EncodeMIDI() ([]byte, error) {
-	rawData := e.RawData
+	rawData := e.Data()
 	if rawData == nil {
-		switch e.Type {
-		case NoteOn:
-			rawData = []byte{byte(e.Key), byte(e.Velocity)}
-		case NoteOff:
-			rawData = []byte{byte(e.Key), byte(e.Velocity)}
-		default:
-			return nil, fmt.Errorf("encoding not implemented for %v", e)
-		}
+		return nil, fmt.Errorf("encoding not implemented for %v", e)
 	}
 
-	rawType := byte(e.Type) | byte(e.Channel)
+	// A NoteOn with zero velocity is written as it was read.
+	rawType := byte(e.Status()) | byte(e.Channel)
 
 	return append([]byte{rawType}, rawData...), nil
 }
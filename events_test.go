@@ -0,0 +1,23 @@
+package midi
+
+import "testing"
+
+func TestGetTempo(t *testing.T) {
+	testcases := []struct {
+		evt   MetaEvent
+		tempo int64
+		ok    bool
+	}{
+		{MetaEvent{Type: SetTempo, Data: []byte{0x07, 0xa1, 0x20}}, 500000, true},
+		{MetaEvent{Type: SetTempo, Data: []byte{0x0f, 0x42, 0x40}}, 1000000, true},
+		{MetaEvent{Type: SetTempo, Data: []byte{0x07, 0xa1}}, 0, false},
+		{MetaEvent{Type: 0x03, Data: []byte{0x07, 0xa1, 0x20}}, 0, false},
+	}
+
+	for _, testcase := range testcases {
+		tempo, ok := testcase.evt.GetTempo()
+		if tempo != testcase.tempo || ok != testcase.ok {
+			t.Errorf("%v.GetTempo() = %d, %v want %d, %v", testcase.evt, tempo, ok, testcase.tempo, testcase.ok)
+		}
+	}
+}
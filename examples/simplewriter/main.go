@@ -24,7 +24,8 @@ func main() {
 		log.Fatalf("error creating %q: %v", *output, err)
 	}
 
-	sw := midi.NewSimpleWriter()
+	sw := midi.NewSimpleWriter(96)
+	sw.NoteAt(0, 30, 0x30, 2000)
 	for i := 0; i < 10; i++ {
 		sw.Play([]int{42 + i}, 0x40, 100)
 		sw.TimeDelta(100)
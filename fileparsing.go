@@ -13,18 +13,18 @@ import (
 )
 
 type Header struct {
-	Format         uint16
-	NumberOfTracks uint16
-	Division       int16
+	Format         uint16 `json:"format"`
+	NumberOfTracks uint16 `json:"numberOfTracks"`
+	Division       int16  `json:"division"`
 }
 
 type Track struct {
-	Events []Event
+	Events []Event `json:"events"`
 }
 
 type File struct {
-	Header *Header
-	Tracks []*Track
+	Header *Header  `json:"header"`
+	Tracks []*Track `json:"tracks"`
 }
 
 func readLiteralExpecting(r io.Reader, s string) error {
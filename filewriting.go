@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
 )
 
 func encodeVarint(n uint64) []byte {
@@ -103,3 +104,13 @@ func encodeEvents(evts []Event) ([]byte, error) {
 
 	return rv, nil
 }
+
+// Write writes the file in Standard MIDI File format.
+func (f *File) Write(w io.Writer) error {
+	data, err := f.encode()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
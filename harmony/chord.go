@@ -0,0 +1,326 @@
+// Package harmony builds chords and scales from their names, as lists of
+// MIDI keys, names chords given their keys, and estimates the key of a
+// piece from its notes.
+package harmony
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/steinarvk/midi/pitch"
+)
+
+type quality struct {
+	name      string
+	aliases   []string
+	intervals []int
+}
+
+// Qualities are listed from most to least common; when a set of keys
+// matches several chords, the one listed first is preferred.
+var qualities = []quality{
+	{"", []string{"maj", "M"}, []int{0, 4, 7}},
+	{"m", []string{"min", "-"}, []int{0, 3, 7}},
+	{"7", []string{"dom7"}, []int{0, 4, 7, 10}},
+	{"maj7", []string{"M7", "Δ7", "Δ"}, []int{0, 4, 7, 11}},
+	{"m7", []string{"min7", "-7"}, []int{0, 3, 7, 10}},
+	{"dim", []string{"o", "°"}, []int{0, 3, 6}},
+	{"aug", []string{"+"}, []int{0, 4, 8}},
+	{"sus4", []string{"sus"}, []int{0, 5, 7}},
+	{"sus2", nil, []int{0, 2, 7}},
+	{"m7b5", []string{"ø7", "ø", "-7b5"}, []int{0, 3, 6, 10}},
+	{"dim7", []string{"o7", "°7"}, []int{0, 3, 6, 9}},
+	{"6", nil, []int{0, 4, 7, 9}},
+	{"m6", []string{"-6"}, []int{0, 3, 7, 9}},
+	{"7sus4", []string{"7sus"}, []int{0, 5, 7, 10}},
+	{"mMaj7", []string{"mM7", "m(maj7)", "-maj7"}, []int{0, 3, 7, 11}},
+	{"aug7", []string{"+7", "7#5"}, []int{0, 4, 8, 10}},
+	{"5", nil, []int{0, 7}},
+	{"add9", nil, []int{0, 4, 7, 14}},
+	{"madd9", nil, []int{0, 3, 7, 14}},
+	{"6/9", []string{"69"}, []int{0, 4, 7, 9, 14}},
+	{"9", nil, []int{0, 4, 7, 10, 14}},
+	{"maj9", []string{"M9"}, []int{0, 4, 7, 11, 14}},
+	{"m9", []string{"min9", "-9"}, []int{0, 3, 7, 10, 14}},
+	{"7b9", nil, []int{0, 4, 7, 10, 13}},
+	{"7#9", nil, []int{0, 4, 7, 10, 15}},
+	{"11", nil, []int{0, 4, 7, 10, 14, 17}},
+	{"m11", []string{"min11", "-11"}, []int{0, 3, 7, 10, 14, 17}},
+	{"13", nil, []int{0, 4, 7, 10, 14, 21}},
+	{"maj13", []string{"M13"}, []int{0, 4, 7, 11, 14, 21}},
+	{"m13", []string{"min13", "-13"}, []int{0, 3, 7, 10, 14, 21}},
+}
+
+// Alterations may follow the quality, optionally in parentheses, as in
+// "C7b9" or "Cmaj7(#11)".
+var alterations = []struct {
+	name     string
+	remove   int
+	interval int
+}{
+	{"b5", 7, 6},
+	{"#5", 7, 8},
+	{"b9", -1, 13},
+	{"#9", -1, 15},
+	{"#11", -1, 18},
+	{"b13", -1, 20},
+	{"add9", -1, 14},
+	{"add11", -1, 17},
+	{"add13", -1, 21},
+}
+
+// Chord is a chord built on a root pitch class. A slash chord has a
+// bass pitch class different from its root.
+type Chord struct {
+	Root      int
+	Bass      int
+	Quality   string
+	Intervals []int
+	Spelling  pitch.Spelling
+}
+
+// ParseChord parses a chord symbol such as "Cmaj7", "F#m7b5/A" or "G13".
+func ParseChord(symbol string) (Chord, error) {
+	root, consumed, err := pitch.ParsePitchClass(symbol)
+	if err != nil {
+		return Chord{}, fmt.Errorf("invalid chord symbol %q: %v", symbol, err)
+	}
+
+	rv := Chord{
+		Root:     pitch.PitchClass(root),
+		Spelling: spellingOf(symbol[:consumed]),
+	}
+
+	rest := symbol[consumed:]
+	var bassName string
+	if i := strings.LastIndex(rest, "/"); i >= 0 && rest[i:] != "/9" {
+		bassName = rest[i+1:]
+		rest = rest[:i]
+	}
+
+	q, n := matchQuality(rest)
+	rv.Quality = q.name
+	rv.Intervals = append([]int(nil), q.intervals...)
+	rest = rest[n:]
+
+	for rest != "" {
+		if rest[0] == '(' || rest[0] == ')' || rest[0] == ',' {
+			rest = rest[1:]
+			continue
+		}
+
+		found := false
+		for _, alt := range alterations {
+			if !strings.HasPrefix(rest, alt.name) {
+				continue
+			}
+			rv.Intervals = alter(rv.Intervals, alt.remove, alt.interval)
+			rv.Quality += alt.name
+			rest = rest[len(alt.name):]
+			found = true
+			break
+		}
+
+		if !found {
+			return Chord{}, fmt.Errorf("invalid chord symbol %q: unknown quality %q", symbol, rest)
+		}
+	}
+
+	rv.Bass = rv.Root
+	if bassName != "" {
+		bass, consumed, err := pitch.ParsePitchClass(bassName)
+		if err != nil || consumed != len(bassName) {
+			return Chord{}, fmt.Errorf("invalid chord symbol %q: bad bass note %q", symbol, bassName)
+		}
+		rv.Bass = pitch.PitchClass(bass)
+	}
+
+	return rv, nil
+}
+
+func spellingOf(noteName string) pitch.Spelling {
+	if strings.Contains(noteName[1:], "b") || strings.Contains(noteName, "♭") {
+		return pitch.Flats
+	}
+	return pitch.Sharps
+}
+
+// matchQuality finds the longest quality name or alias prefixing s.
+func matchQuality(s string) (quality, int) {
+	best, bestLen := qualities[0], 0
+	for _, q := range qualities {
+		for _, name := range append([]string{q.name}, q.aliases...) {
+			if len(name) > bestLen && strings.HasPrefix(s, name) {
+				best, bestLen = q, len(name)
+			}
+		}
+	}
+	return best, bestLen
+}
+
+func alter(intervals []int, remove, add int) []int {
+	var rv []int
+	for _, x := range intervals {
+		if x != remove {
+			rv = append(rv, x)
+		}
+	}
+	rv = append(rv, add)
+	sort.Ints(rv)
+	return rv
+}
+
+// String formats the chord as a symbol, such as "F#m7b5/A".
+func (c Chord) String() string {
+	rv := pitch.PitchClassName(c.Root, c.Spelling) + c.Quality
+	if c.Bass != c.Root {
+		rv += "/" + pitch.PitchClassName(c.Bass, c.Spelling)
+	}
+	return rv
+}
+
+// PitchClasses returns the distinct pitch classes of the chord, bass first.
+func (c Chord) PitchClasses() []int {
+	rv := []int{c.Bass}
+	seen := map[int]bool{c.Bass: true}
+	for _, x := range c.Intervals {
+		pc := pitch.PitchClass(c.Root + x)
+		if !seen[pc] {
+			seen[pc] = true
+			rv = append(rv, pc)
+		}
+	}
+	return rv
+}
+
+// Keys returns the chord in close root position, starting from the lowest
+// key at or above low with the bass pitch class.
+func (c Chord) Keys(low int) []int {
+	bass := low + pitch.PitchClass(c.Bass-low)
+	root := bass + pitch.PitchClass(c.Root-bass)
+
+	var rv []int
+	if bass != root {
+		rv = append(rv, bass)
+	}
+	for _, x := range c.Intervals {
+		rv = append(rv, root+x)
+	}
+	return rv
+}
+
+// Inversion returns the chord's keys starting from low, with the n lowest
+// chord tones moved up an octave.
+func (c Chord) Inversion(low, n int) []int {
+	return Invert(c.Keys(low), n)
+}
+
+// Voice returns the chord's keys fitted into the range [low, high]. The
+// bass is placed as low as possible; other tones that would exceed high
+// are moved down by octaves, and are dropped if they then fall below the
+// bass or duplicate another key.
+func (c Chord) Voice(low, high int) []int {
+	keys := c.Keys(low)
+	if len(keys) == 0 || keys[0] > high {
+		return nil
+	}
+
+	bass := keys[0]
+	rv := []int{bass}
+	seen := map[int]bool{bass: true}
+	for _, key := range keys[1:] {
+		for key > high {
+			key -= 12
+		}
+		if key <= bass || seen[key] {
+			continue
+		}
+		seen[key] = true
+		rv = append(rv, key)
+	}
+	sort.Ints(rv)
+	return rv
+}
+
+// Invert moves the lowest key up an octave n times, or the highest key
+// down an octave -n times if n is negative.
+func Invert(keys []int, n int) []int {
+	rv := append([]int(nil), keys...)
+	sort.Ints(rv)
+	if len(rv) == 0 {
+		return rv
+	}
+
+	for ; n > 0; n-- {
+		rv = append(rv[1:], rv[0]+12)
+		for len(rv) > 1 && rv[len(rv)-1] <= rv[len(rv)-2] {
+			rv[len(rv)-1] += 12
+		}
+	}
+	for ; n < 0; n++ {
+		lowest := rv[len(rv)-1] - 12
+		for lowest >= rv[0] {
+			lowest -= 12
+		}
+		rv = append([]int{lowest}, rv[:len(rv)-1]...)
+	}
+
+	return rv
+}
+
+// IdentifyChord names the chord formed by a set of keys, taking the lowest
+// key as the bass. Chords in root position are preferred; otherwise the
+// most common quality wins, and the chord is named as a slash chord.
+func IdentifyChord(keys []int) (Chord, bool) {
+	if len(keys) == 0 {
+		return Chord{}, false
+	}
+
+	lowest := keys[0]
+	present := map[int]bool{}
+	for _, key := range keys {
+		present[pitch.PitchClass(key)] = true
+		if key < lowest {
+			lowest = key
+		}
+	}
+	bass := pitch.PitchClass(lowest)
+
+	makeChord := func(root int, q quality) Chord {
+		return Chord{
+			Root:      root,
+			Bass:      bass,
+			Quality:   q.name,
+			Intervals: append([]int(nil), q.intervals...),
+		}
+	}
+
+	for _, q := range qualities {
+		if matchesExactly(bass, q.intervals, present) {
+			return makeChord(bass, q), true
+		}
+	}
+
+	for _, q := range qualities {
+		for root := 0; root < 12; root++ {
+			if present[root] && root != bass && matchesExactly(root, q.intervals, present) {
+				return makeChord(root, q), true
+			}
+		}
+	}
+
+	return Chord{}, false
+}
+
+func matchesExactly(root int, intervals []int, present map[int]bool) bool {
+	covered := map[int]bool{}
+	for _, x := range intervals {
+		pc := pitch.PitchClass(root + x)
+		if !present[pc] {
+			return false
+		}
+		covered[pc] = true
+	}
+	return len(covered) == len(present)
+}
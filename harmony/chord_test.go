@@ -0,0 +1,109 @@
+package harmony
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseChord(t *testing.T) {
+	testcases := []struct {
+		symbol string
+		low    int
+		want   []int
+	}{
+		{"C", 60, []int{60, 64, 67}},
+		{"Cmaj7", 60, []int{60, 64, 67, 71}},
+		{"Am", 57, []int{57, 60, 64}},
+		{"G13", 43, []int{43, 47, 50, 53, 57, 64}},
+		{"F#m7b5/A", 45, []int{45, 54, 57, 60, 64}},
+		{"Bbsus4", 58, []int{58, 63, 65}},
+		{"C7(#9)", 60, []int{60, 64, 67, 70, 75}},
+		{"C7b5", 60, []int{60, 64, 66, 70}},
+		{"C6/9", 60, []int{60, 64, 67, 69, 74}},
+		{"Ebdim7", 60, []int{63, 66, 69, 72}},
+	}
+
+	for _, testcase := range testcases {
+		chord, err := ParseChord(testcase.symbol)
+		if err != nil {
+			t.Errorf("ParseChord(%q) = err: %v", testcase.symbol, err)
+			continue
+		}
+		if got := chord.Keys(testcase.low); !reflect.DeepEqual(got, testcase.want) {
+			t.Errorf("ParseChord(%q).Keys(%d) = %v want %v", testcase.symbol, testcase.low, got, testcase.want)
+		}
+	}
+}
+
+func TestParseChordErrors(t *testing.T) {
+	for _, symbol := range []string{"", "H7", "Cfoo", "C/H"} {
+		if chord, err := ParseChord(symbol); err == nil {
+			t.Errorf("ParseChord(%q) = %v want error", symbol, chord)
+		}
+	}
+}
+
+func TestChordSymbolRoundtrip(t *testing.T) {
+	symbols := []string{
+		"C", "Cm", "C7", "Cmaj7", "Dm7", "Bdim", "Caug", "Dsus4", "Dsus2",
+		"F#m7b5", "Edim7", "G7sus4", "AmMaj7", "C9", "Cmaj9", "Cm9", "G13",
+		"C/E", "C/G", "Am7/G", "G7/B", "Eb", "Bbmaj7",
+	}
+
+	for _, symbol := range symbols {
+		chord, err := ParseChord(symbol)
+		if err != nil {
+			t.Errorf("ParseChord(%q) = err: %v", symbol, err)
+			continue
+		}
+		if got := chord.String(); got != symbol {
+			t.Errorf("ParseChord(%q).String() = %q", symbol, got)
+		}
+
+		keys := chord.Keys(48)
+		identified, ok := IdentifyChord(keys)
+		if !ok {
+			t.Errorf("IdentifyChord(%v) failed for %q", keys, symbol)
+			continue
+		}
+		identified.Spelling = chord.Spelling
+		if got := identified.String(); got != symbol {
+			t.Errorf("IdentifyChord(%v) = %q want %q", keys, got, symbol)
+		}
+	}
+}
+
+func TestInversions(t *testing.T) {
+	chord, err := ParseChord("C")
+	if err != nil {
+		t.Fatalf("ParseChord(%q) = err: %v", "C", err)
+	}
+
+	if got, want := chord.Inversion(60, 1), []int{64, 67, 72}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Inversion(60, 1) = %v want %v", got, want)
+	}
+	if got, want := chord.Inversion(60, 2), []int{67, 72, 76}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Inversion(60, 2) = %v want %v", got, want)
+	}
+	if got, want := chord.Inversion(60, -1), []int{55, 60, 64}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Inversion(60, -1) = %v want %v", got, want)
+	}
+
+	identified, _ := IdentifyChord(chord.Inversion(60, 1))
+	if got := identified.String(); got != "C/E" {
+		t.Errorf("IdentifyChord(first inversion) = %q want %q", got, "C/E")
+	}
+}
+
+func TestVoice(t *testing.T) {
+	chord, err := ParseChord("G13")
+	if err != nil {
+		t.Fatalf("ParseChord(%q) = err: %v", "G13", err)
+	}
+
+	got := chord.Voice(52, 64)
+	want := []int{55, 57, 59, 62, 64}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Voice(52, 64) = %v want %v", got, want)
+	}
+}
@@ -0,0 +1,183 @@
+package harmony
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/steinarvk/midi"
+)
+
+// The Krumhansl-Kessler key profiles: how well each pitch class, counted
+// from the tonic, was judged to fit a major or minor key.
+var (
+	majorProfile = [12]float64{6.35, 2.23, 3.48, 2.33, 4.38, 4.09, 2.52, 5.19, 2.39, 3.66, 2.29, 2.88}
+	minorProfile = [12]float64{6.33, 2.68, 3.52, 5.38, 2.60, 3.53, 2.54, 4.75, 3.98, 2.69, 3.34, 3.17}
+
+	majorKeyNames = []string{"C", "Db", "D", "Eb", "E", "F", "F#", "G", "Ab", "A", "Bb", "B"}
+	minorKeyNames = []string{"C", "C#", "D", "Eb", "E", "F", "F#", "G", "G#", "A", "Bb", "B"}
+)
+
+const drumChannel = 9
+
+// Key is a major or minor key, with how well it correlates with the
+// notes it was detected from.
+type Key struct {
+	Tonic       int
+	Minor       bool
+	Correlation float64
+}
+
+// String names the key, as in "Eb major" or "F# minor".
+func (k Key) String() string {
+	if k.Minor {
+		return minorKeyNames[k.Tonic] + " minor"
+	}
+	return majorKeyNames[k.Tonic] + " major"
+}
+
+// Fifths returns the number of sharps (if positive) or flats (if
+// negative) in the key signature, as in a KeySignature meta event. It
+// follows the spelling of String, so Eb minor has six flats.
+func (k Key) Fifths() int {
+	tonic, names := k.Tonic, majorKeyNames
+	if k.Minor {
+		tonic, names = tonic+3, minorKeyNames
+	}
+	fifths := (tonic * 7) % 12
+	if fifths > 6 {
+		fifths -= 12
+	}
+
+	name := names[k.Tonic]
+	switch {
+	case strings.HasSuffix(name, "b") && fifths > 0:
+		fifths -= 12
+	case strings.HasSuffix(name, "#") && fifths < 0:
+		fifths += 12
+	}
+	return fifths
+}
+
+// PitchClassProfile returns how long each pitch class sounds in the notes,
+// in ticks, leaving out notes on the percussion channel.
+func PitchClassProfile(notes []midi.Note) [12]float64 {
+	var rv [12]float64
+	for _, n := range notes {
+		if n.Channel != drumChannel {
+			rv[n.Key%12] += float64(n.Duration)
+		}
+	}
+	return rv
+}
+
+// DetectKey estimates the key of a set of notes, such as those of a track
+// or a whole file, with the Krumhansl-Schmuckler algorithm: the duration
+// of each pitch class is correlated with the profile of every major and
+// minor key. All 24 keys are returned, best first; there are none if no
+// pitched notes sound.
+func DetectKey(notes []midi.Note) []Key {
+	return rankKeys(PitchClassProfile(notes))
+}
+
+func rankKeys(profile [12]float64) []Key {
+	var total float64
+	for _, x := range profile {
+		total += x
+	}
+	if total == 0 {
+		return nil
+	}
+
+	var rv []Key
+	for tonic := 0; tonic < 12; tonic++ {
+		var rotated [12]float64
+		for i := range rotated {
+			rotated[i] = profile[(tonic+i)%12]
+		}
+		rv = append(rv,
+			Key{Tonic: tonic, Correlation: correlation(rotated, majorProfile)},
+			Key{Tonic: tonic, Minor: true, Correlation: correlation(rotated, minorProfile)})
+	}
+
+	sort.SliceStable(rv, func(i, j int) bool {
+		return rv[i].Correlation > rv[j].Correlation
+	})
+	return rv
+}
+
+func correlation(x, y [12]float64) float64 {
+	var mx, my float64
+	for i := range x {
+		mx += x[i] / 12
+		my += y[i] / 12
+	}
+
+	var sxy, sxx, syy float64
+	for i := range x {
+		dx, dy := x[i]-mx, y[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
+
+// KeyWindow is the key detected over a stretch of time.
+type KeyWindow struct {
+	Start, End int64
+	Keys       []Key
+}
+
+// DetectKeyWindows detects keys over windows of the given length in
+// ticks, starting every hop ticks, to follow modulations. Notes count
+// only for the time they sound within a window. Windows in which no
+// pitched notes sound are left out.
+func DetectKeyWindows(notes []midi.Note, window, hop int64) []KeyWindow {
+	if window <= 0 || hop <= 0 {
+		return nil
+	}
+
+	var end int64
+	for _, n := range notes {
+		if n.End() > end {
+			end = n.End()
+		}
+	}
+
+	var rv []KeyWindow
+	for start := int64(0); start < end; start += hop {
+		var profile [12]float64
+		for _, n := range notes {
+			if n.Channel == drumChannel {
+				continue
+			}
+			overlap := minInt64(n.End(), start+window) - maxInt64(n.Start, start)
+			if overlap > 0 {
+				profile[n.Key%12] += float64(overlap)
+			}
+		}
+
+		if keys := rankKeys(profile); keys != nil {
+			rv = append(rv, KeyWindow{Start: start, End: start + window, Keys: keys})
+		}
+	}
+	return rv
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
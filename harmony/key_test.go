@@ -0,0 +1,94 @@
+package harmony
+
+import (
+	"testing"
+
+	"github.com/steinarvk/midi"
+)
+
+func melody(start int64, keys []int, durations []int64) []midi.Note {
+	var rv []midi.Note
+	for i, key := range keys {
+		d := durations[i%len(durations)]
+		rv = append(rv, midi.Note{Key: key, Velocity: 80, Start: start, Duration: d})
+		start += d
+	}
+	return rv
+}
+
+func TestDetectKey(t *testing.T) {
+	testcases := []struct {
+		notes []midi.Note
+		want  string
+	}{
+		{melody(0, []int{60, 62, 64, 65, 67, 69, 71, 72, 67, 64, 60}, []int64{480}), "C major"},
+		{melody(0, []int{57, 60, 64, 69, 68, 69, 71, 72, 71, 69, 64, 57}, []int64{960, 480}), "A minor"},
+		{melody(0, []int{63, 65, 67, 68, 70, 72, 74, 75, 70, 67, 63}, []int64{480}), "Eb major"},
+		{melody(0, []int{66, 69, 73, 78, 77, 78, 73, 69, 66}, []int64{960, 480}), "F# minor"},
+	}
+
+	for _, testcase := range testcases {
+		keys := DetectKey(testcase.notes)
+		if len(keys) != 24 {
+			t.Errorf("DetectKey() = %v want 24 keys", keys)
+			continue
+		}
+		if got := keys[0].String(); got != testcase.want {
+			t.Errorf("DetectKey() = %v want %s first", keys[:3], testcase.want)
+		}
+		for i := 1; i < len(keys); i++ {
+			if keys[i].Correlation > keys[i-1].Correlation {
+				t.Errorf("DetectKey() = %v not ranked by correlation", keys)
+				break
+			}
+		}
+	}
+
+	drums := []midi.Note{{Channel: 9, Key: 42, Duration: 100}}
+	if got := DetectKey(drums); got != nil {
+		t.Errorf("DetectKey(drums) = %v want none", got)
+	}
+}
+
+func TestDetectKeyWindows(t *testing.T) {
+	cMajor := []int{60, 64, 67, 72, 65, 69, 67, 71, 72, 67, 64, 60}
+	abMajor := []int{68, 72, 75, 80, 73, 77, 75, 79, 80, 75, 72, 68}
+
+	notes := append(melody(0, cMajor, []int64{480}), melody(5760, abMajor, []int64{480})...)
+
+	windows := DetectKeyWindows(notes, 5760, 5760)
+	if len(windows) != 2 {
+		t.Fatalf("DetectKeyWindows() = %v want 2 windows", windows)
+	}
+	for i, want := range []string{"C major", "Ab major"} {
+		if got := windows[i].Keys[0].String(); got != want {
+			t.Errorf("DetectKeyWindows() window %d = %s want %s", i, got, want)
+		}
+	}
+	if windows[1].Start != 5760 || windows[1].End != 11520 {
+		t.Errorf("DetectKeyWindows() window 1 = %d..%d want 5760..11520", windows[1].Start, windows[1].End)
+	}
+}
+
+func TestFifths(t *testing.T) {
+	testcases := []struct {
+		key  Key
+		want int
+	}{
+		{Key{Tonic: 0}, 0},
+		{Key{Tonic: 7}, 1},
+		{Key{Tonic: 4, Minor: true}, 1},
+		{Key{Tonic: 3}, -3},
+		{Key{Tonic: 0, Minor: true}, -3},
+		{Key{Tonic: 6}, 6},
+		{Key{Tonic: 1}, -5},
+		{Key{Tonic: 3, Minor: true}, -6},
+		{Key{Tonic: 8, Minor: true}, 5},
+	}
+
+	for _, testcase := range testcases {
+		if got := testcase.key.Fifths(); got != testcase.want {
+			t.Errorf("%v.Fifths() = %d want %d", testcase.key, got, testcase.want)
+		}
+	}
+}
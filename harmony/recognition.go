@@ -0,0 +1,144 @@
+package harmony
+
+import (
+	"github.com/steinarvk/midi"
+	"github.com/steinarvk/midi/pitch"
+)
+
+// TimedChord is a chord sounding from Start until End, in ticks.
+type TimedChord struct {
+	Start, End int64
+	Chord      Chord
+}
+
+// RecognizeChord names the chord sounding between two ticks. Each pitch
+// class is weighted by how long it sounds, and each chord within an
+// octave is scored by the weight of its tones less that of other pitch
+// classes, with a penalty for tones that are missing or barely sound
+// (such as passing notes). The bass is the lowest key sounding for a good
+// part of the time; a chord whose root is not in the bass is named as a
+// slash chord, but chords in root position are slightly preferred, as are
+// more common chords. Notes on the percussion channel are left out.
+func RecognizeChord(notes []midi.Note, start, end int64) (Chord, bool) {
+	var weights [12]float64
+	var total, longest float64
+	type overlap struct {
+		key      int
+		duration float64
+	}
+	var overlaps []overlap
+
+	for _, n := range notes {
+		if n.Channel == drumChannel {
+			continue
+		}
+		d := float64(minInt64(n.End(), end) - maxInt64(n.Start, start))
+		if d <= 0 {
+			continue
+		}
+		weights[n.Key%12] += d
+		total += d
+		if d > longest {
+			longest = d
+		}
+		overlaps = append(overlaps, overlap{n.Key, d})
+	}
+	if total == 0 {
+		return Chord{}, false
+	}
+
+	bassKey := -1
+	for _, o := range overlaps {
+		if o.duration >= longest/4 && (bassKey < 0 || o.key < bassKey) {
+			bassKey = o.key
+		}
+	}
+	bass := bassKey % 12
+
+	var strongest float64
+	for _, w := range weights {
+		if w > strongest {
+			strongest = w
+		}
+	}
+
+	var best Chord
+	bestScore := -1e9
+	for root := 0; root < 12; root++ {
+		if weights[root] == 0 {
+			continue
+		}
+		for i, q := range qualities {
+			if q.intervals[len(q.intervals)-1] >= 12 {
+				continue
+			}
+
+			var in float64
+			missing := 0
+			for _, x := range q.intervals {
+				w := weights[(root+x)%12]
+				in += w
+				if w < strongest/4 {
+					missing++
+				}
+			}
+
+			score := (2*in-total)/total - 0.5*float64(missing)/float64(len(q.intervals)) - 0.001*float64(i)
+			if root == bass {
+				score += 0.05
+			}
+
+			if score > bestScore {
+				bestScore = score
+				best = Chord{
+					Root:      root,
+					Bass:      bass,
+					Quality:   q.name,
+					Intervals: append([]int(nil), q.intervals...),
+					Spelling:  rootSpelling(root),
+				}
+			}
+		}
+	}
+
+	return best, true
+}
+
+func rootSpelling(root int) pitch.Spelling {
+	switch root {
+	case 1, 3, 8, 10:
+		return pitch.Flats
+	}
+	return pitch.Sharps
+}
+
+// RecognizeChords names the chords sounding in consecutive windows of the
+// given length in ticks, such as a beat or a bar. Windows in which the
+// same chord is named are merged, and windows in which nothing sounds are
+// left out.
+func RecognizeChords(notes []midi.Note, window int64) []TimedChord {
+	if window <= 0 {
+		return nil
+	}
+
+	var end int64
+	for _, n := range notes {
+		if n.End() > end {
+			end = n.End()
+		}
+	}
+
+	var rv []TimedChord
+	for start := int64(0); start < end; start += window {
+		c, ok := RecognizeChord(notes, start, start+window)
+		if !ok {
+			continue
+		}
+		if last := len(rv) - 1; last >= 0 && rv[last].End == start && rv[last].Chord.String() == c.String() {
+			rv[last].End = start + window
+			continue
+		}
+		rv = append(rv, TimedChord{Start: start, End: start + window, Chord: c})
+	}
+	return rv
+}
@@ -0,0 +1,82 @@
+package harmony
+
+import (
+	"testing"
+
+	"github.com/steinarvk/midi"
+)
+
+func block(start, duration int64, keys ...int) []midi.Note {
+	var rv []midi.Note
+	for _, key := range keys {
+		rv = append(rv, midi.Note{Key: key, Velocity: 80, Start: start, Duration: duration})
+	}
+	return rv
+}
+
+func TestRecognizeChord(t *testing.T) {
+	testcases := []struct {
+		notes []midi.Note
+		want  string
+	}{
+		{block(0, 480, 48, 64, 67, 72), "C"},
+		{block(0, 480, 57, 60, 64), "Am"},
+		{block(0, 480, 43, 59, 62, 65), "G7"},
+		{block(0, 480, 48, 64, 67, 71), "Cmaj7"},
+		{block(0, 480, 50, 65, 69, 72), "Dm7"},
+		{block(0, 480, 47, 62, 65, 69), "Bm7b5"},
+		{block(0, 480, 43, 60, 62, 67), "Gsus4"},
+		{block(0, 480, 50, 57, 64), "Dsus2"},
+		{block(0, 480, 52, 60, 67), "C/E"},
+		{block(0, 480, 55, 64, 72), "C/G"},
+		{block(0, 480, 41, 55, 59, 62), "G7/F"},
+		{block(0, 480, 45, 55, 59, 62), "G/A"},
+		{block(0, 480, 46, 62, 65), "Bb"},
+		{block(0, 480, 48, 57, 64, 67), "C6"},
+		{block(0, 480, 45, 60, 64, 67), "Am7"},
+		{append(block(0, 480, 48, 64, 67), block(120, 60, 69)...), "C"},
+		{block(0, 480, 42, 57, 61), "F#m"},
+	}
+
+	for _, testcase := range testcases {
+		c, ok := RecognizeChord(testcase.notes, 0, 480)
+		if !ok {
+			t.Errorf("RecognizeChord(%v) = none want %s", testcase.notes, testcase.want)
+			continue
+		}
+		if got := c.String(); got != testcase.want {
+			t.Errorf("RecognizeChord(%v) = %s want %s", testcase.notes, got, testcase.want)
+		}
+	}
+
+	if c, ok := RecognizeChord(block(0, 480, 60), 480, 960); ok {
+		t.Errorf("RecognizeChord() of silence = %v want none", c)
+	}
+}
+
+func TestRecognizeChords(t *testing.T) {
+	var notes []midi.Note
+	notes = append(notes, block(0, 960, 48, 64, 67)...)
+	notes = append(notes, block(960, 480, 53, 65, 69)...)
+	notes = append(notes, block(1920, 480, 43, 59, 62, 65)...)
+
+	got := RecognizeChords(notes, 480)
+
+	want := []struct {
+		start, end int64
+		name       string
+	}{
+		{0, 960, "C"},
+		{960, 1440, "F"},
+		{1920, 2400, "G7"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("RecognizeChords() = %v want %d chords", got, len(want))
+	}
+	for i, w := range want {
+		if got[i].Start != w.start || got[i].End != w.end || got[i].Chord.String() != w.name {
+			t.Errorf("RecognizeChords()[%d] = %d..%d %s want %d..%d %s", i, got[i].Start, got[i].End, got[i].Chord, w.start, w.end, w.name)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package harmony
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/steinarvk/midi/pitch"
+)
+
+var modes = map[string][]int{
+	"major":            {0, 2, 4, 5, 7, 9, 11},
+	"ionian":           {0, 2, 4, 5, 7, 9, 11},
+	"dorian":           {0, 2, 3, 5, 7, 9, 10},
+	"phrygian":         {0, 1, 3, 5, 7, 8, 10},
+	"lydian":           {0, 2, 4, 6, 7, 9, 11},
+	"mixolydian":       {0, 2, 4, 5, 7, 9, 10},
+	"minor":            {0, 2, 3, 5, 7, 8, 10},
+	"natural minor":    {0, 2, 3, 5, 7, 8, 10},
+	"aeolian":          {0, 2, 3, 5, 7, 8, 10},
+	"locrian":          {0, 1, 3, 5, 6, 8, 10},
+	"harmonic minor":   {0, 2, 3, 5, 7, 8, 11},
+	"melodic minor":    {0, 2, 3, 5, 7, 9, 11},
+	"pentatonic":       {0, 2, 4, 7, 9},
+	"major pentatonic": {0, 2, 4, 7, 9},
+	"minor pentatonic": {0, 3, 5, 7, 10},
+	"blues":            {0, 3, 5, 6, 7, 10},
+	"whole tone":       {0, 2, 4, 6, 8, 10},
+	"chromatic":        {0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+}
+
+// Scale is a mode built on a root pitch class.
+type Scale struct {
+	Root      int
+	Mode      string
+	Intervals []int
+	Spelling  pitch.Spelling
+}
+
+// ParseScale parses a scale name such as "D dorian" or "Bb harmonic minor".
+func ParseScale(name string) (Scale, error) {
+	fields := strings.Fields(name)
+	if len(fields) < 2 {
+		return Scale{}, fmt.Errorf("invalid scale %q: want root and mode", name)
+	}
+
+	root, consumed, err := pitch.ParsePitchClass(fields[0])
+	if err != nil || consumed != len(fields[0]) {
+		return Scale{}, fmt.Errorf("invalid scale %q: bad root %q", name, fields[0])
+	}
+
+	mode := strings.ToLower(strings.Join(fields[1:], " "))
+	intervals, ok := modes[mode]
+	if !ok {
+		return Scale{}, fmt.Errorf("invalid scale %q: unknown mode %q", name, mode)
+	}
+
+	return Scale{
+		Root:      pitch.PitchClass(root),
+		Mode:      mode,
+		Intervals: append([]int(nil), intervals...),
+		Spelling:  spellingOf(fields[0]),
+	}, nil
+}
+
+// Modes returns the names of all known modes.
+func Modes() []string {
+	var rv []string
+	for name := range modes {
+		rv = append(rv, name)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+func (s Scale) String() string {
+	return pitch.PitchClassName(s.Root, s.Spelling) + " " + s.Mode
+}
+
+// Contains checks whether a key belongs to the scale.
+func (s Scale) Contains(key int) bool {
+	pc := pitch.PitchClass(key - s.Root)
+	for _, x := range s.Intervals {
+		if x == pc {
+			return true
+		}
+	}
+	return false
+}
+
+// Keys returns the keys of the scale within [low, high], ascending.
+func (s Scale) Keys(low, high int) []int {
+	var rv []int
+	for key := low; key <= high; key++ {
+		if s.Contains(key) {
+			rv = append(rv, key)
+		}
+	}
+	return rv
+}
+
+// Degree returns the key of the n-th degree of the scale (counting from
+// zero) above the root at the given key. Negative degrees descend.
+func (s Scale) Degree(root, n int) int {
+	size := len(s.Intervals)
+	octaves := n / size
+	i := n % size
+	if i < 0 {
+		i += size
+		octaves--
+	}
+	return root + 12*octaves + s.Intervals[i]
+}
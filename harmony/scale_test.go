@@ -0,0 +1,50 @@
+package harmony
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseScale(t *testing.T) {
+	testcases := []struct {
+		name string
+		want []int
+	}{
+		{"C major", []int{60, 62, 64, 65, 67, 69, 71, 72}},
+		{"D dorian", []int{62, 64, 65, 67, 69, 71, 72, 74}},
+		{"A minor pentatonic", []int{57, 60, 62, 64, 67, 69}},
+		{"Bb harmonic minor", []int{58, 60, 61, 63, 65, 66, 69, 70}},
+	}
+
+	for _, testcase := range testcases {
+		scale, err := ParseScale(testcase.name)
+		if err != nil {
+			t.Errorf("ParseScale(%q) = err: %v", testcase.name, err)
+			continue
+		}
+		low, high := testcase.want[0], testcase.want[len(testcase.want)-1]
+		if got := scale.Keys(low, high); !reflect.DeepEqual(got, testcase.want) {
+			t.Errorf("ParseScale(%q).Keys(%d, %d) = %v want %v", testcase.name, low, high, got, testcase.want)
+		}
+		if got := scale.String(); got != testcase.name {
+			t.Errorf("ParseScale(%q).String() = %q", testcase.name, got)
+		}
+	}
+
+	if _, err := ParseScale("C hypermixolydian"); err == nil {
+		t.Errorf("ParseScale(%q) succeeded; want error", "C hypermixolydian")
+	}
+}
+
+func TestScaleDegree(t *testing.T) {
+	scale, err := ParseScale("C major")
+	if err != nil {
+		t.Fatalf("ParseScale = err: %v", err)
+	}
+
+	for n, want := range map[int]int{0: 60, 2: 64, 7: 72, 9: 76, -1: 59, -7: 48, -8: 47} {
+		if got := scale.Degree(60, n); got != want {
+			t.Errorf("Degree(60, %d) = %d want %d", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package midi
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Events are marshalled to JSON as objects with a "type" field, one of
+// "timeDelta", "midi", "meta" or "sysex":
+//
+//	{"type": "timeDelta", "ticks": 96}
+//	{"type": "midi", "message": "noteOn", "channel": 0, "key": 60, "velocity": 100}
+//	{"type": "meta", "metaType": 3, "name": "TrackName", "text": "Melody"}
+//	{"type": "meta", "metaType": 81, "name": "TempoSetting", "data": "B6Eg"}
+//	{"type": "sysex", "data": "8H5/CQH3"}
+//
+// The fields of a MIDI event depend on its message: "key" and "velocity"
+// for noteOn and noteOff; "key" and "pressure" for aftertouch;
+// "controller" and "value" for controlChange; "program" for
+// programChange; "pressure" for channelPressure; and a 14-bit "value"
+// for pitchBend. A noteOn with zero velocity stays a noteOn.
+//
+// Meta event data is given as "text" if it is valid UTF-8 and the event
+// type holds text, and as base64 "data" otherwise. The "name" field is
+// informational. Sysex data includes the leading f0 or f7 byte.
+
+type jsonTimeDeltaEvent struct {
+	Type  string `json:"type"`
+	Ticks int64  `json:"ticks"`
+}
+
+type jsonMetaEvent struct {
+	Type     string  `json:"type"`
+	MetaType *int    `json:"metaType"`
+	Name     string  `json:"name,omitempty"`
+	Text     *string `json:"text,omitempty"`
+	Data     []byte  `json:"data,omitempty"`
+}
+
+type jsonSysexEvent struct {
+	Type string `json:"type"`
+	Data []byte `json:"data"`
+}
+
+type jsonMIDIEvent struct {
+	Type       string `json:"type"`
+	Message    string `json:"message"`
+	Channel    int    `json:"channel"`
+	Key        *int   `json:"key,omitempty"`
+	Velocity   *int   `json:"velocity,omitempty"`
+	Pressure   *int   `json:"pressure,omitempty"`
+	Controller *int   `json:"controller,omitempty"`
+	Value      *int   `json:"value,omitempty"`
+	Program    *int   `json:"program,omitempty"`
+}
+
+func (j *jsonMIDIEvent) field(name string) **int {
+	switch name {
+	case "key":
+		return &j.Key
+	case "velocity":
+		return &j.Velocity
+	case "pressure":
+		return &j.Pressure
+	case "controller":
+		return &j.Controller
+	case "value":
+		return &j.Value
+	case "program":
+		return &j.Program
+	}
+	panic(fmt.Errorf("no such JSON MIDI event field: %q", name))
+}
+
+var jsonMIDIMessages = []struct {
+	name   string
+	status MIDIEventType
+	fields []string
+}{
+	{"noteOff", NoteOff, []string{"key", "velocity"}},
+	{"noteOn", NoteOn, []string{"key", "velocity"}},
+	{"aftertouch", Aftertouch, []string{"key", "pressure"}},
+	{"controlChange", ControllerChange, []string{"controller", "value"}},
+	{"programChange", ProgramChange, []string{"program"}},
+	{"channelPressure", ChannelPressure, []string{"pressure"}},
+	{"pitchBend", PitchBend, []string{"value"}},
+}
+
+func (td TimeDeltaEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonTimeDeltaEvent{Type: "timeDelta", Ticks: int64(td)})
+}
+
+func (td *TimeDeltaEvent) UnmarshalJSON(data []byte) error {
+	var j jsonTimeDeltaEvent
+	if err := unmarshalTypedJSON(data, "timeDelta", &j); err != nil {
+		return err
+	}
+	if j.Ticks < 0 {
+		return fmt.Errorf("negative time delta %d", j.Ticks)
+	}
+	*td = TimeDeltaEvent(j.Ticks)
+	return nil
+}
+
+func (e MetaEvent) MarshalJSON() ([]byte, error) {
+	metaType := int(e.Type)
+	j := jsonMetaEvent{Type: "meta", MetaType: &metaType}
+	j.Name, _ = MetaEventName(e.Type)
+	if e.IsText() && utf8.Valid(e.Data) {
+		text := string(e.Data)
+		j.Text = &text
+	} else {
+		j.Data = e.Data
+	}
+	return json.Marshal(j)
+}
+
+func (e *MetaEvent) UnmarshalJSON(data []byte) error {
+	var j jsonMetaEvent
+	if err := unmarshalTypedJSON(data, "meta", &j); err != nil {
+		return err
+	}
+
+	switch {
+	case j.MetaType != nil:
+		if *j.MetaType < 0 || *j.MetaType > 0xFF {
+			return fmt.Errorf("meta event type %d out of range", *j.MetaType)
+		}
+		e.Type = byte(*j.MetaType)
+	case j.Name != "":
+		metaType, ok := MetaEventType(j.Name)
+		if !ok {
+			return fmt.Errorf("unknown meta event name %q", j.Name)
+		}
+		e.Type = metaType
+	default:
+		return fmt.Errorf("meta event without metaType")
+	}
+
+	e.Data = j.Data
+	if j.Text != nil {
+		if j.Data != nil {
+			return fmt.Errorf("meta event has both text and data")
+		}
+		if *j.Text != "" {
+			e.Data = []byte(*j.Text)
+		}
+	}
+	return nil
+}
+
+func (e SysexEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonSysexEvent{Type: "sysex", Data: []byte(e)})
+}
+
+func (e *SysexEvent) UnmarshalJSON(data []byte) error {
+	var j jsonSysexEvent
+	if err := unmarshalTypedJSON(data, "sysex", &j); err != nil {
+		return err
+	}
+	if len(j.Data) == 0 || (j.Data[0] != 0xF0 && j.Data[0] != 0xF7) {
+		return fmt.Errorf("sysex data must begin with f0 or f7")
+	}
+	*e = SysexEvent(j.Data)
+	return nil
+}
+
+func (e MIDIEvent) MarshalJSON() ([]byte, error) {
+	status := e.Status()
+	data := e.Data()
+
+	for _, msg := range jsonMIDIMessages {
+		if msg.status != status {
+			continue
+		}
+
+		j := jsonMIDIEvent{Type: "midi", Message: msg.name, Channel: e.Channel}
+		if status == PitchBend {
+			if len(data) != 2 {
+				return nil, fmt.Errorf("pitch bend without data: %v", e)
+			}
+			value := int(data[0]) | int(data[1])<<7
+			j.Value = &value
+			return json.Marshal(j)
+		}
+
+		if len(data) != len(msg.fields) {
+			return nil, fmt.Errorf("%s: want %d data byte(s), got %v", msg.name, len(msg.fields), data)
+		}
+		for i, name := range msg.fields {
+			value := int(data[i])
+			*j.field(name) = &value
+		}
+		return json.Marshal(j)
+	}
+
+	return nil, fmt.Errorf("unable to marshal MIDI event %v", e)
+}
+
+func (e *MIDIEvent) UnmarshalJSON(data []byte) error {
+	var j jsonMIDIEvent
+	if err := unmarshalTypedJSON(data, "midi", &j); err != nil {
+		return err
+	}
+
+	if j.Channel < 0 || j.Channel > 15 {
+		return fmt.Errorf("channel %d out of range", j.Channel)
+	}
+
+	for _, msg := range jsonMIDIMessages {
+		if msg.name != j.Message {
+			continue
+		}
+
+		var values []int
+		for _, name := range msg.fields {
+			p := *j.field(name)
+			if p == nil {
+				return fmt.Errorf("%s: missing %q", msg.name, name)
+			}
+			values = append(values, *p)
+		}
+
+		var raw []byte
+		if msg.status == PitchBend {
+			if values[0] < 0 || values[0] > 0x3FFF {
+				return fmt.Errorf("%s: value %d out of range", msg.name, values[0])
+			}
+			raw = []byte{byte(values[0] & 0x7F), byte(values[0] >> 7)}
+		} else {
+			for i, value := range values {
+				if value < 0 || value > 0x7F {
+					return fmt.Errorf("%s: %s %d out of range", msg.name, msg.fields[i], value)
+				}
+				raw = append(raw, byte(value))
+			}
+		}
+
+		evt, err := NewMIDIEvent(byte(msg.status)|byte(j.Channel), raw)
+		if err != nil {
+			return err
+		}
+		*e = evt
+		return nil
+	}
+
+	return fmt.Errorf("unknown MIDI message %q", j.Message)
+}
+
+func unmarshalTypedJSON(data []byte, wantType string, v interface{}) error {
+	var typed struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return err
+	}
+	if typed.Type != wantType {
+		return fmt.Errorf("want event type %q, got %q", wantType, typed.Type)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// UnmarshalEventJSON decodes a single event of any type.
+func UnmarshalEventJSON(data []byte) (Event, error) {
+	var typed struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, err
+	}
+
+	switch typed.Type {
+	case "timeDelta":
+		var e TimeDeltaEvent
+		err := e.UnmarshalJSON(data)
+		return e, err
+	case "midi":
+		var e MIDIEvent
+		err := e.UnmarshalJSON(data)
+		return e, err
+	case "meta":
+		var e MetaEvent
+		err := e.UnmarshalJSON(data)
+		return e, err
+	case "sysex":
+		var e SysexEvent
+		err := e.UnmarshalJSON(data)
+		return e, err
+	}
+
+	return nil, fmt.Errorf("unknown event type %q", typed.Type)
+}
+
+func (t *Track) UnmarshalJSON(data []byte) error {
+	var j struct {
+		Events []json.RawMessage `json:"events"`
+	}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	t.Events = nil
+	for i, raw := range j.Events {
+		evt, err := UnmarshalEventJSON(raw)
+		if err != nil {
+			return fmt.Errorf("event #%d: %v", i, err)
+		}
+		t.Events = append(t.Events, evt)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package midi
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONRoundtrip(t *testing.T) {
+	data := append(
+		[]byte("MThd\x00\x00\x00\x06\x00\x01\x00\x02\x00\x60"),
+		append(
+			[]byte("MTrk\x00\x00\x00\x19\x00\xff\x03\x06Melody\x00\xff\x51\x03\x07\xa1\x20\x00\xff\x00\x00\x00\xff\x2f\x00"),
+			[]byte("MTrk\x00\x00\x00\x2f\x00\xc1\x49\x00\x91\x3c\x64\x60\x3c\x00\x00\x81\x3e\x40\x00\xe1\x7f\x7f"+
+				"\x00\xb1\x07\x64\x00\xa1\x3e\x10\x00\xd1\x22\x00\xf0\x05\x7e\x7f\x09\x01\xf7\x00\xff\x01\x02\xff\xfe\x00\xff\x2f\x00")...)...)
+
+	f, err := parse(bytes.NewBuffer(data), true)
+	if err != nil {
+		t.Fatalf("parse(%02x, true) = err: %v", data, err)
+	}
+
+	encoded, err := json.Marshal(f)
+	if err != nil {
+		t.Fatalf("json.Marshal(f) = err: %v", err)
+	}
+
+	var decoded File
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal(%s) = err: %v", encoded, err)
+	}
+
+	if !reflect.DeepEqual(&decoded, f) {
+		t.Errorf("json.Unmarshal(json.Marshal(f)) = %v want %v; JSON: %s", &decoded, f, encoded)
+	}
+}
+
+func TestEventJSON(t *testing.T) {
+	noteOn, _ := NewMIDIEvent(0x92, []byte{0x3c, 0x00})
+	pitchBend, _ := NewMIDIEvent(0xe0, []byte{0x00, 0x40})
+
+	testcases := []struct {
+		evt  Event
+		want string
+	}{
+		{TimeDeltaEvent(96), `{"type":"timeDelta","ticks":96}`},
+		{noteOn, `{"type":"midi","message":"noteOn","channel":2,"key":60,"velocity":0}`},
+		{pitchBend, `{"type":"midi","message":"pitchBend","channel":0,"value":8192}`},
+		{MIDIEvent{Type: ProgramChange, Channel: 9, ProgramNumber: 5}, `{"type":"midi","message":"programChange","channel":9,"program":5}`},
+		{MetaEvent{Type: 0x03, Data: []byte("Lead")}, `{"type":"meta","metaType":3,"name":"TrackName","text":"Lead"}`},
+		{MetaEvent{Type: 0x51, Data: []byte{0x07, 0xa1, 0x20}}, `{"type":"meta","metaType":81,"name":"TempoSetting","data":"B6Eg"}`},
+		{SysexEvent{0xf0, 0x01, 0xf7}, `{"type":"sysex","data":"8AH3"}`},
+	}
+
+	for _, testcase := range testcases {
+		got, err := json.Marshal(testcase.evt)
+		if err != nil {
+			t.Errorf("json.Marshal(%v) = err: %v", testcase.evt, err)
+			continue
+		}
+		if string(got) != testcase.want {
+			t.Errorf("json.Marshal(%v) = %s want %s", testcase.evt, got, testcase.want)
+		}
+	}
+}
+
+func TestUnmarshalEventJSONErrors(t *testing.T) {
+	testcases := []string{
+		`{"type":"bogus"}`,
+		`{"type":"timeDelta","ticks":-1}`,
+		`{"type":"midi","message":"noteOn","channel":0,"key":60}`,
+		`{"type":"midi","message":"noteOn","channel":16,"key":60,"velocity":1}`,
+		`{"type":"midi","message":"noteOn","channel":0,"key":128,"velocity":1}`,
+		`{"type":"midi","message":"bogus","channel":0}`,
+		`{"type":"meta","name":"Bogus"}`,
+		`{"type":"sysex","data":"AQI="}`,
+	}
+
+	for _, testcase := range testcases {
+		if evt, err := UnmarshalEventJSON([]byte(testcase)); err == nil {
+			t.Errorf("UnmarshalEventJSON(%s) = %v want error", testcase, evt)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+// Package lilypond writes melody tracks as LilyPond scores.
+package lilypond
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/steinarvk/midi"
+	"github.com/steinarvk/midi/score"
+)
+
+const (
+	Version = "2.24.0"
+	letters = "cdefgab"
+)
+
+type Options struct {
+	// Relative writes pitches in \relative mode, with octave marks only
+	// where a note is more than a fourth away from the one before it.
+	Relative bool
+}
+
+// Write writes one track of a file as a LilyPond score. The track should
+// be quantized, with every note and rest a whole number of 64th notes
+// long. Bars follow the time signatures, notes are spelled according to
+// the key signatures, and notes crossing a bar line or not fitting a
+// single note value are split and tied. LyricText events in the track
+// are set under the notes with \addlyrics.
+func Write(w io.Writer, f *midi.File, trackNo int, opts Options) error {
+	s, err := score.Layout(f, trackNo)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "\\version %q\n\n", Version)
+	if s.Title != "" {
+		fmt.Fprintf(bw, "\\header {\n  title = %s\n}\n\n", quote(s.Title))
+	}
+
+	p := &pitchWriter{relative: opts.Relative, previous: 4 * 7}
+	if opts.Relative {
+		bw.WriteString("melody = \\relative c' {\n")
+	} else {
+		bw.WriteString("melody = {\n")
+	}
+
+	if isLow(f.Tracks[trackNo]) {
+		bw.WriteString("  \\clef bass\n")
+	} else {
+		bw.WriteString("  \\clef treble\n")
+	}
+	if s.Tempo > 0 {
+		fmt.Fprintf(bw, "  \\tempo 4 = %d\n", int(math.Floor(60e6/float64(s.Tempo)+0.5)))
+	}
+
+	for _, bar := range s.Bars {
+		var tokens []string
+		if bar.KeyChanged {
+			tokens = append(tokens, keyCommand(bar.Key))
+		}
+		if bar.MeterChanged {
+			tokens = append(tokens, fmt.Sprintf("\\time %d/%d", bar.Meter.Numerator, bar.Meter.Denominator))
+		}
+
+		for _, item := range bar.Items {
+			values, err := s.Values(item.Duration)
+			if err != nil {
+				return fmt.Errorf("%s at tick %d is not quantized", describe(item), item.Start)
+			}
+			for i, value := range values {
+				token := p.item(item, bar.Key) + duration(value)
+				if !item.IsRest() && (i < len(values)-1 || item.TiedToNext) {
+					token += "~"
+				}
+				tokens = append(tokens, token)
+			}
+		}
+
+		fmt.Fprintf(bw, "  %s |\n", strings.Join(tokens, " "))
+	}
+	bw.WriteString("  \\bar \"|.\"\n}\n\n")
+
+	bw.WriteString("\\score {\n  \\new Voice = \"melody\" \\melody\n")
+	if syllables := lyrics(s, f.Tracks[trackNo]); len(syllables) > 0 {
+		fmt.Fprintf(bw, "  \\addlyrics { %s }\n", strings.Join(syllables, " "))
+	}
+	bw.WriteString("  \\layout { }\n}\n")
+
+	return bw.Flush()
+}
+
+func describe(item score.Item) string {
+	if item.IsRest() {
+		return "rest"
+	}
+	return fmt.Sprintf("note %v", item.Keys)
+}
+
+func quote(s string) string {
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	s = strings.Replace(s, "\"", "\\\"", -1)
+	return "\"" + s + "\""
+}
+
+// isLow reports whether the track's notes are mostly below middle C.
+func isLow(track *midi.Track) bool {
+	notes := track.Notes()
+	var sum int
+	for _, note := range notes {
+		sum += note.Key
+	}
+	return sum < 57*len(notes)
+}
+
+func keyCommand(k score.Key) string {
+	letter, alter := k.Tonic()
+	mode := "\\major"
+	if k.Minor {
+		mode = "\\minor"
+	}
+	return fmt.Sprintf("\\key %s %s", pitchName(letter, alter), mode)
+}
+
+// pitchName names a pitch class in LilyPond's default (Dutch) note names.
+func pitchName(letter byte, alter int) string {
+	name := strings.ToLower(string(letter))
+	switch {
+	case alter > 0:
+		return name + strings.Repeat("is", alter)
+	case alter < 0 && (name == "e" || name == "a"):
+		return name + strings.Repeat("s"+name[:1], -alter-1) + "s"
+	case alter < 0:
+		return name + strings.Repeat("es", -alter)
+	}
+	return name
+}
+
+func duration(v score.Value) string {
+	return fmt.Sprintf("%d%s", v.Denominator, strings.Repeat(".", v.Dots))
+}
+
+// pitchWriter writes pitches with octave marks, remembering the previous
+// pitch (as a number of diatonic steps from C0) in relative mode.
+type pitchWriter struct {
+	relative bool
+	previous int
+}
+
+func (p *pitchWriter) item(item score.Item, k score.Key) string {
+	if item.IsRest() {
+		return "r"
+	}
+	if len(item.Keys) == 1 {
+		return p.pitch(item.Keys[0], k)
+	}
+
+	// In a chord each note is relative to the one before it, and the
+	// chord as a whole is relative to the first note of the last chord.
+	var names []string
+	var first int
+	for i, key := range item.Keys {
+		names = append(names, p.pitch(key, k))
+		if i == 0 {
+			first = p.previous
+		}
+	}
+	p.previous = first
+	return "<" + strings.Join(names, " ") + ">"
+}
+
+func (p *pitchWriter) pitch(key int, k score.Key) string {
+	letter, alter, octave := k.Spell(key)
+	name := pitchName(letter, alter)
+	step := octave*7 + strings.IndexByte(letters, strings.ToLower(string(letter))[0])
+
+	// Without marks, a note is at octave 3 in absolute mode, and within
+	// a fourth of the previous note in relative mode.
+	unmarked := 3*7 + step%7
+	if p.relative {
+		unmarked = step%7 + 7*int(math.Floor(float64(p.previous-step%7)/7+0.5))
+		p.previous = step
+	}
+
+	marks := (step - unmarked) / 7
+	switch {
+	case marks > 0:
+		name += strings.Repeat("'", marks)
+	case marks < 0:
+		name += strings.Repeat(",", -marks)
+	}
+	return name
+}
+
+type onset struct {
+	start, end int64
+	text       string
+}
+
+// lyrics aligns the track's LyricText events with its notes: each goes to
+// the note (including any notes tied to it) sounding when it occurs, or
+// failing that to the next note. Notes without lyrics get a skip.
+func lyrics(s *score.Score, track *midi.Track) []string {
+	var onsets []*onset
+	for _, bar := range s.Bars {
+		for _, item := range bar.Items {
+			switch {
+			case item.IsRest():
+			case item.TiedFromPrevious && len(onsets) > 0:
+				onsets[len(onsets)-1].end = item.Start + item.Duration
+			default:
+				onsets = append(onsets, &onset{start: item.Start, end: item.Start + item.Duration})
+			}
+		}
+	}
+
+	found := false
+	for _, te := range track.TimedEvents() {
+		meta, ok := te.Event.(midi.MetaEvent)
+		if !ok || meta.Type != midi.LyricText {
+			continue
+		}
+		for _, o := range onsets {
+			if te.Tick < o.end {
+				o.text += string(meta.Data)
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	var rv []string
+	for _, o := range onsets {
+		rv = append(rv, syllable(o.text))
+	}
+	for len(rv) > 0 && rv[len(rv)-1] == "_" {
+		rv = rv[:len(rv)-1]
+	}
+	return rv
+}
+
+// syllable formats lyric text for one note. Karaoke line and paragraph
+// marks are dropped, and a trailing hyphen joins it to the next syllable.
+func syllable(text string) string {
+	text = strings.TrimLeft(text, "/\\")
+	text = strings.TrimSpace(text)
+
+	hyphen := ""
+	if strings.HasSuffix(text, "-") {
+		text = strings.TrimSpace(strings.TrimSuffix(text, "-"))
+		hyphen = " --"
+	}
+	if text == "" {
+		return "_" + hyphen
+	}
+
+	plain := true
+	for _, r := range text {
+		if r == '"' || r == '\\' || r == '{' || r == '}' || r == '_' || r == ' ' || r == '~' || (r >= '0' && r <= '9') {
+			plain = false
+		}
+	}
+	if !plain {
+		text = quote(text)
+	}
+	return text + hyphen
+}
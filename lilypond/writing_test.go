@@ -0,0 +1,144 @@
+package lilypond
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/steinarvk/midi"
+	"github.com/steinarvk/midi/score"
+)
+
+func testFile() *midi.File {
+	sw := midi.NewSimpleWriter(480)
+	sw.Event(midi.MetaEvent{Type: midi.TrackName, Data: []byte("Song")})
+	sw.Event(midi.NewTimeSignatureEvent(3, 4))
+	sw.Event(midi.NewKeySignatureEvent(1, false))
+	sw.Event(midi.NewTempoEvent(500000))
+
+	sw.Event(midi.MetaEvent{Type: midi.LyricText, Data: []byte("Hel-")})
+	sw.Play([]int{67}, 80, 480)
+	sw.Event(midi.MetaEvent{Type: midi.LyricText, Data: []byte("lo ")})
+	sw.Play([]int{66}, 80, 480)
+	sw.Play([]int{65}, 80, 480)
+	sw.Event(midi.MetaEvent{Type: midi.LyricText, Data: []byte("/world ")})
+	sw.Play([]int{72}, 80, 1200)
+	sw.EventAt(sw.Cursor()+10, midi.MetaEvent{Type: midi.LyricText, Data: []byte("again")})
+	sw.Play([]int{74}, 80, 480)
+
+	return sw.File()
+}
+
+func TestWrite(t *testing.T) {
+	testcases := []struct {
+		opts  Options
+		notes string
+	}{
+		{
+			Options{},
+			`melody = {
+  \clef treble
+  \tempo 4 = 120
+  \key g \major \time 3/4 g'4 fis'4 f'4 |
+  c''2~ c''8 d''8~ |
+  d''8 |
+`,
+		},
+		{
+			Options{Relative: true},
+			`melody = \relative c' {
+  \clef treble
+  \tempo 4 = 120
+  \key g \major \time 3/4 g'4 fis4 f4 |
+  c'2~ c8 d8~ |
+  d8 |
+`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		buf := bytes.NewBuffer(nil)
+		if err := Write(buf, testFile(), 0, testcase.opts); err != nil {
+			t.Errorf("Write(%+v) = err: %v", testcase.opts, err)
+			continue
+		}
+
+		want := `\version "2.24.0"
+
+\header {
+  title = "Song"
+}
+
+` + testcase.notes + `  \bar "|."
+}
+
+\score {
+  \new Voice = "melody" \melody
+  \addlyrics { Hel -- lo _ world again }
+  \layout { }
+}
+`
+		if got := buf.String(); got != want {
+			t.Errorf("Write(%+v) = %s want %s", testcase.opts, got, want)
+		}
+	}
+}
+
+func TestRelativeChords(t *testing.T) {
+	p := &pitchWriter{relative: true, previous: 4 * 7}
+
+	var got []string
+	for _, keys := range [][]int{{60, 64, 67}, {65, 69, 72}, {55}, {48, 79}} {
+		got = append(got, p.item(score.Item{Keys: keys}, score.Key{}))
+	}
+
+	want := []string{"<c e g>", "<f a c>", "g,", "<c, g'''>"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("item(%d) = %q want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	testcases := []struct {
+		letter byte
+		alter  int
+		want   string
+	}{
+		{'C', 0, "c"},
+		{'F', 1, "fis"},
+		{'G', 2, "gisis"},
+		{'B', -1, "bes"},
+		{'E', -1, "es"},
+		{'A', -1, "as"},
+		{'E', -2, "eses"},
+		{'A', -2, "asas"},
+		{'D', -2, "deses"},
+	}
+
+	for _, testcase := range testcases {
+		if got := pitchName(testcase.letter, testcase.alter); got != testcase.want {
+			t.Errorf("pitchName(%c, %d) = %q want %q", testcase.letter, testcase.alter, got, testcase.want)
+		}
+	}
+}
+
+func TestSyllable(t *testing.T) {
+	testcases := map[string]string{
+		"Hel-":    "Hel --",
+		"lo ":     "lo",
+		"\\Love":  "Love",
+		"":        "_",
+		"2nd":     `"2nd"`,
+		"don't":   "don't",
+		"a\"b":    `"a\"b"`,
+		" - ":     "_ --",
+		"{brace}": `"{brace}"`,
+	}
+
+	for text, want := range testcases {
+		if got := syllable(text); got != want {
+			t.Errorf("syllable(%q) = %q want %q", text, got, want)
+		}
+	}
+}
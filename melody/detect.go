@@ -0,0 +1,185 @@
+package melody
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/steinarvk/midi"
+)
+
+var (
+	melodyNames = []string{"melody", "vocal", "voice", "vox", "lead", "sing", "solo", "tune", "theme"}
+	otherNames  = []string{"bass", "drum", "perc", "chord", "pad", "accomp", "rhythm", "comp", "harmony", "backing"}
+)
+
+// Candidate is a track that may carry the melody, with the features it
+// was scored on.
+type Candidate struct {
+	Track int
+	Score float64
+
+	Name string
+
+	// Program is the track's first program, or -1 if it selects none.
+	Program int
+
+	// Polyphony is the fraction of notes struck while another note of
+	// the track is sounding.
+	Polyphony float64
+
+	MeanKey float64
+	Range   int
+
+	// Density is the number of notes per quarter note over the file.
+	Density float64
+}
+
+// RankTracks scores the tracks of a file by how likely they are to carry
+// the melody, best first. A melody is expected to be monophonic, in a
+// middle register, within two octaves, with a moderate number of notes;
+// the track name and the General MIDI program family count for or against
+// it. Tracks without notes and tracks on the percussion channel are left
+// out.
+func RankTracks(f *midi.File) []Candidate {
+	var length int64
+	for _, track := range f.Tracks {
+		if l := track.Length(); l > length {
+			length = l
+		}
+	}
+	quarters := float64(length) / float64(f.Header.Division)
+	if f.Header.Division <= 0 || quarters <= 0 {
+		quarters = 1
+	}
+
+	var rv []Candidate
+	for i, track := range f.Tracks {
+		notes := track.Notes()
+		if len(notes) == 0 || isDrumTrack(notes) {
+			continue
+		}
+
+		c := Candidate{
+			Track:     i,
+			Name:      trackName(track),
+			Program:   program(track),
+			Polyphony: polyphony(notes),
+			Density:   float64(len(notes)) / quarters,
+		}
+
+		low, high, sum := notes[0].Key, notes[0].Key, 0
+		for _, n := range notes {
+			sum += n.Key
+			if n.Key < low {
+				low = n.Key
+			}
+			if n.Key > high {
+				high = n.Key
+			}
+		}
+		c.MeanKey = float64(sum) / float64(len(notes))
+		c.Range = high - low
+
+		c.Score = score(c)
+		rv = append(rv, c)
+	}
+
+	sort.SliceStable(rv, func(i, j int) bool {
+		return rv[i].Score > rv[j].Score
+	})
+	return rv
+}
+
+func score(c Candidate) float64 {
+	s := 2 * (1 - c.Polyphony)
+
+	s += 1 - math.Min(1, math.Abs(c.MeanKey-70)/18)
+
+	switch {
+	case c.Range > 24:
+		s -= math.Min(1, float64(c.Range-24)/12)
+	case c.Range < 5:
+		s -= 0.5
+	}
+
+	switch {
+	case c.Density < 0.5:
+		s += c.Density / 0.5
+	case c.Density > 4:
+		s += math.Max(0, 1-(c.Density-4)/4)
+	default:
+		s++
+	}
+
+	name := strings.ToLower(c.Name)
+	switch {
+	case containsAny(name, melodyNames):
+		s += 2
+	case containsAny(name, otherNames):
+		s -= 2
+	}
+
+	switch p := c.Program; {
+	case p < 0:
+	case p >= 52 && p <= 54, p >= 56 && p <= 87:
+		// Choir and voice, brass, reed, pipe and synth lead.
+		s += 0.5
+	case p >= 32 && p <= 39, p >= 88:
+		// Bass, synth pads and effects, percussive and sound effects.
+		s--
+	}
+
+	return s
+}
+
+func containsAny(s string, words []string) bool {
+	for _, word := range words {
+		if strings.Contains(s, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDrumTrack(notes []midi.Note) bool {
+	for _, n := range notes {
+		if n.Channel != DrumChannel {
+			return false
+		}
+	}
+	return true
+}
+
+func trackName(track *midi.Track) string {
+	for _, evt := range track.Events {
+		if meta, ok := evt.(midi.MetaEvent); ok && meta.Type == midi.TrackName {
+			return string(meta.Data)
+		}
+	}
+	return ""
+}
+
+func program(track *midi.Track) int {
+	for _, evt := range track.Events {
+		if m, ok := evt.(midi.MIDIEvent); ok && m.Type == midi.ProgramChange {
+			return m.ProgramNumber
+		}
+	}
+	return -1
+}
+
+// polyphony expects notes ordered by start time.
+func polyphony(notes []midi.Note) float64 {
+	var overlapping int
+	var end int64 = -1
+	for i, n := range notes {
+		if i > 0 && n.Start < end {
+			overlapping++
+		}
+		if n.End() > end {
+			end = n.End()
+		}
+	}
+	return float64(overlapping) / float64(len(notes))
+}
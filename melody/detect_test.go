@@ -0,0 +1,83 @@
+package melody
+
+import (
+	"testing"
+
+	"github.com/steinarvk/midi"
+)
+
+func testTracks(named bool) *midi.File {
+	name := func(sw *midi.SimpleWriter, s string) {
+		if named {
+			sw.Event(midi.MetaEvent{Type: midi.TrackName, Data: []byte(s)})
+		}
+	}
+
+	conductor := midi.NewSimpleWriter(480)
+	conductor.Event(midi.NewTempoEvent(500000))
+
+	chords := midi.NewSimpleWriter(480)
+	name(chords, "Piano")
+	for bar := 0; bar < 8; bar++ {
+		for _, key := range []int{55, 60, 64} {
+			addNote(chords, 0, key, bar*1920, 1920)
+		}
+	}
+
+	lead := midi.NewSimpleWriter(480)
+	name(lead, "Lead Vocal")
+	lead.Event(midi.MIDIEvent{Type: midi.ProgramChange, Channel: 1, ProgramNumber: 73})
+	for i, key := range []int{72, 74, 76, 77, 79, 77, 76, 74, 72, 71, 72, 74, 76, 74, 72, 72} {
+		addNote(lead, 1, key, i*960, 900)
+	}
+
+	bass := midi.NewSimpleWriter(480)
+	name(bass, "Bass")
+	bass.Event(midi.MIDIEvent{Type: midi.ProgramChange, Channel: 2, ProgramNumber: 33})
+	for i := 0; i < 32; i++ {
+		addNote(bass, 2, 36+7*(i%2), i*480, 480)
+	}
+
+	drums := midi.NewSimpleWriter(480)
+	for i := 0; i < 64; i++ {
+		addNote(drums, DrumChannel, 42, i*240, 120)
+	}
+
+	var tracks []*midi.Track
+	for _, sw := range []*midi.SimpleWriter{conductor, chords, lead, bass, drums} {
+		tracks = append(tracks, sw.File().Tracks[0])
+	}
+	return &midi.File{
+		Header: &midi.Header{Format: 1, NumberOfTracks: uint16(len(tracks)), Division: 480},
+		Tracks: tracks,
+	}
+}
+
+func TestRankTracks(t *testing.T) {
+	for _, named := range []bool{true, false} {
+		got := RankTracks(testTracks(named))
+
+		if len(got) != 3 {
+			t.Fatalf("RankTracks(named=%v) = %v want 3 candidates", named, got)
+		}
+		if got[0].Track != 2 {
+			t.Errorf("RankTracks(named=%v) = %v want track 2 first", named, got)
+		}
+		for i := 1; i < len(got); i++ {
+			if got[i].Score > got[i-1].Score {
+				t.Errorf("RankTracks(named=%v) = %v not ranked by score", named, got)
+			}
+		}
+	}
+
+	got := RankTracks(testTracks(true))
+	lead := got[0]
+	if lead.Name != "Lead Vocal" || lead.Program != 73 || lead.Polyphony != 0 || lead.Range != 8 {
+		t.Errorf("RankTracks() lead = %+v", lead)
+	}
+	for _, c := range got {
+		if c.Track == 1 && c.Polyphony < 0.6 {
+			t.Errorf("RankTracks() chord polyphony = %v want at least 0.6", c.Polyphony)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package melody finds the melody in MIDI files: the track most likely to
+// carry it, and a single line of notes drawn from polyphonic music.
+package melody
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/steinarvk/midi"
+)
+
+// DrumChannel is the General MIDI percussion channel (channel 10,
+// numbered from zero).
+const DrumChannel = 9
+
+type Options struct {
+	// MinDuration leaves out notes shorter than this many ticks, such as
+	// grace notes and ornaments.
+	MinDuration int64
+
+	// IgnoreDrums leaves out notes on the percussion channel.
+	IgnoreDrums bool
+
+	// ExcludeChannels leaves out notes on these channels, such as those
+	// carrying the accompaniment.
+	ExcludeChannels []int
+}
+
+func (o Options) keep(n midi.Note) bool {
+	if n.Duration < o.MinDuration {
+		return false
+	}
+	if o.IgnoreDrums && n.Channel == DrumChannel {
+		return false
+	}
+	for _, ch := range o.ExcludeChannels {
+		if n.Channel == ch {
+			return false
+		}
+	}
+	return true
+}
+
+// Skyline reduces a track to a single line by keeping the highest note:
+// of notes struck together only the highest is kept, a note is cut short
+// when a higher one begins, and notes beginning under a higher note that
+// is still sounding are left out. The new track holds the remaining notes
+// along with the track's tempo, time signature and key signature events.
+func Skyline(track *midi.Track, opts Options) *midi.Track {
+	return skyline([]*midi.Track{track}, opts)
+}
+
+// SkylineFile is like Skyline, but draws a single line from the notes of
+// every track in the file.
+func SkylineFile(f *midi.File, opts Options) *midi.Track {
+	return skyline(f.Tracks, opts)
+}
+
+func skyline(tracks []*midi.Track, opts Options) *midi.Track {
+	var notes []midi.Note
+	for _, track := range tracks {
+		for _, n := range track.Notes() {
+			if opts.keep(n) {
+				notes = append(notes, n)
+			}
+		}
+	}
+	sort.SliceStable(notes, func(i, j int) bool {
+		if notes[i].Start != notes[j].Start {
+			return notes[i].Start < notes[j].Start
+		}
+		return notes[i].Key > notes[j].Key
+	})
+
+	var line []midi.Note
+	for i, n := range notes {
+		if i > 0 && notes[i-1].Start == n.Start {
+			continue
+		}
+		if len(line) > 0 {
+			last := &line[len(line)-1]
+			if last.End() > n.Start {
+				if n.Key <= last.Key {
+					continue
+				}
+				last.Duration = n.Start - last.Start
+			}
+		}
+		line = append(line, n)
+	}
+
+	sw := midi.NewSimpleWriter(0)
+	var end int64
+	var seen []midi.TimedEvent
+	for _, track := range tracks {
+		for _, te := range track.TimedEvents() {
+			meta, ok := te.Event.(midi.MetaEvent)
+			if !ok || (meta.Type != midi.SetTempo && meta.Type != midi.TimeSignature && meta.Type != midi.KeySignature) {
+				continue
+			}
+			if containsEvent(seen, te) {
+				continue
+			}
+			seen = append(seen, te)
+			sw.EventAt(int(te.Tick), meta)
+			if te.Tick > end {
+				end = te.Tick
+			}
+		}
+	}
+
+	for _, n := range line {
+		sw.EventAt(int(n.Start), midi.MIDIEvent{Type: midi.NoteOn, Channel: n.Channel, Key: n.Key, Velocity: n.Velocity})
+		sw.EventAt(int(n.End()), midi.MIDIEvent{Type: midi.NoteOff, Channel: n.Channel, Key: n.Key})
+		if n.End() > end {
+			end = n.End()
+		}
+	}
+
+	sw.EventAt(int(end), midi.MetaEvent{Type: midi.EndOfTrack})
+	return sw.File().Tracks[0]
+}
+
+func containsEvent(events []midi.TimedEvent, te midi.TimedEvent) bool {
+	for _, other := range events {
+		if reflect.DeepEqual(other, te) {
+			return true
+		}
+	}
+	return false
+}
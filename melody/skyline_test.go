@@ -0,0 +1,109 @@
+package melody
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/steinarvk/midi"
+)
+
+func addNote(sw *midi.SimpleWriter, channel, key, start, duration int) {
+	sw.EventAt(start, midi.MIDIEvent{Type: midi.NoteOn, Channel: channel, Key: key, Velocity: 80})
+	sw.EventAt(start+duration, midi.MIDIEvent{Type: midi.NoteOff, Channel: channel, Key: key})
+}
+
+func note(channel, key int, start, duration int64) midi.Note {
+	return midi.Note{Channel: channel, Key: key, Velocity: 80, Start: start, Duration: duration}
+}
+
+func TestSkyline(t *testing.T) {
+	sw := midi.NewSimpleWriter(480)
+	sw.Event(midi.NewTempoEvent(400000))
+	for _, key := range []int{60, 64, 67} {
+		addNote(sw, 0, key, 0, 480)
+	}
+	addNote(sw, 0, 72, 480, 960)
+	addNote(sw, 0, 48, 480, 1920)
+	addNote(sw, 0, 76, 960, 240)
+	addNote(sw, 0, 70, 1200, 240)
+	addNote(sw, 0, 65, 1440, 480)
+	addNote(sw, 0, 79, 1500, 10)
+	addNote(sw, 0, 64, 1920, 480)
+	addNote(sw, DrumChannel, 81, 1920, 240)
+	addNote(sw, 2, 100, 1920, 240)
+	track := sw.File().Tracks[0]
+
+	got := Skyline(track, Options{MinDuration: 20, IgnoreDrums: true, ExcludeChannels: []int{2}})
+
+	want := []midi.Note{
+		note(0, 67, 0, 480),
+		note(0, 72, 480, 480),
+		note(0, 76, 960, 240),
+		note(0, 70, 1200, 240),
+		note(0, 65, 1440, 480),
+		note(0, 64, 1920, 480),
+	}
+	if notes := got.Notes(); !reflect.DeepEqual(notes, want) {
+		t.Errorf("Skyline() = %v want %v", notes, want)
+	}
+	if !reflect.DeepEqual(got.Events[0], midi.NewTempoEvent(400000)) {
+		t.Errorf("Skyline() starts with %v want tempo", got.Events[0])
+	}
+	timed := got.TimedEvents()
+	wantEnd := midi.TimedEvent{Tick: 2400, Event: midi.MetaEvent{Type: midi.EndOfTrack}}
+	if last := timed[len(timed)-1]; !reflect.DeepEqual(last, wantEnd) {
+		t.Errorf("Skyline() ends with %v want %v", last, wantEnd)
+	}
+
+	got = Skyline(track, Options{})
+	want = []midi.Note{
+		note(0, 67, 0, 480),
+		note(0, 72, 480, 480),
+		note(0, 76, 960, 240),
+		note(0, 70, 1200, 240),
+		note(0, 65, 1440, 60),
+		note(0, 79, 1500, 10),
+		note(2, 100, 1920, 240),
+	}
+	if notes := got.Notes(); !reflect.DeepEqual(notes, want) {
+		t.Errorf("Skyline() = %v want %v", notes, want)
+	}
+}
+
+func TestSkylineFile(t *testing.T) {
+	lead := midi.NewSimpleWriter(480)
+	lead.Event(midi.NewTempoEvent(400000))
+	addNote(lead, 0, 72, 0, 480)
+	addNote(lead, 0, 74, 960, 480)
+
+	accompaniment := midi.NewSimpleWriter(480)
+	accompaniment.Event(midi.NewTempoEvent(400000))
+	addNote(accompaniment, 1, 60, 0, 1920)
+	addNote(accompaniment, 1, 64, 480, 480)
+
+	f := &midi.File{
+		Header: &midi.Header{Format: 1, NumberOfTracks: 2, Division: 480},
+		Tracks: []*midi.Track{lead.File().Tracks[0], accompaniment.File().Tracks[0]},
+	}
+
+	got := SkylineFile(f, Options{})
+
+	want := []midi.Note{
+		note(0, 72, 0, 480),
+		note(1, 64, 480, 480),
+		note(0, 74, 960, 480),
+	}
+	if notes := got.Notes(); !reflect.DeepEqual(notes, want) {
+		t.Errorf("SkylineFile() = %v want %v", notes, want)
+	}
+
+	var tempos int
+	for _, evt := range got.Events {
+		if meta, ok := evt.(midi.MetaEvent); ok && meta.Type == midi.SetTempo {
+			tempos++
+		}
+	}
+	if tempos != 1 {
+		t.Errorf("SkylineFile() has %d tempo events want 1", tempos)
+	}
+}
@@ -0,0 +1,76 @@
+// Package midicsv reads and writes MIDI files in the CSV dialect of John
+// Walker's midicsv and csvmidi tools.
+//
+// Every record begins with a track number and an absolute time in ticks,
+// followed by the record type and its fields:
+//
+//	0, 0, Header, 1, 2, 480
+//	1, 0, Start_track
+//	1, 0, Title_t, "Melody"
+//	1, 0, Tempo, 500000
+//	1, 0, End_track
+//	2, 0, Start_track
+//	2, 0, Note_on_c, 0, 60, 100
+//	2, 480, Note_off_c, 0, 60, 64
+//	2, 480, End_track
+//	0, 0, End_of_file
+//
+// The End_track record stands for the EndOfTrack meta event. Meta events
+// whose data does not fit their usual layout are written as
+// Unknown_meta_event records, so that every file round-trips losslessly.
+package midicsv
+
+const (
+	metaSequenceNumber    byte = 0x00
+	metaText              byte = 0x01
+	metaCopyright         byte = 0x02
+	metaTrackName         byte = 0x03
+	metaInstrumentName    byte = 0x04
+	metaLyric             byte = 0x05
+	metaMarker            byte = 0x06
+	metaCuePoint          byte = 0x07
+	metaChannelPrefix     byte = 0x20
+	metaPort              byte = 0x21
+	metaEndOfTrack        byte = 0x2F
+	metaTempo             byte = 0x51
+	metaSMPTEOffset       byte = 0x54
+	metaTimeSignature     byte = 0x58
+	metaKeySignature      byte = 0x59
+	metaSequencerSpecific byte = 0x7F
+)
+
+var textRecords = map[byte]string{
+	metaText:           "Text_t",
+	metaCopyright:      "Copyright_t",
+	metaTrackName:      "Title_t",
+	metaInstrumentName: "Instrument_name_t",
+	metaLyric:          "Lyric_t",
+	metaMarker:         "Marker_t",
+	metaCuePoint:       "Cue_point_t",
+}
+
+// numericRecords are meta events whose data is a fixed number of bytes,
+// each written as a separate field.
+var numericRecords = map[byte]struct {
+	name    string
+	dataLen int
+}{
+	metaChannelPrefix: {"Channel_prefix", 1},
+	metaPort:          {"MIDI_port", 1},
+	metaSMPTEOffset:   {"SMPTE_offset", 5},
+	metaTimeSignature: {"Time_signature", 4},
+}
+
+var channelRecords = []struct {
+	name    string
+	status  byte
+	dataLen int
+}{
+	{"Note_off_c", 0x80, 2},
+	{"Note_on_c", 0x90, 2},
+	{"Poly_aftertouch_c", 0xA0, 2},
+	{"Control_c", 0xB0, 2},
+	{"Program_c", 0xC0, 1},
+	{"Channel_aftertouch_c", 0xD0, 1},
+	{"Pitch_bend_c", 0xE0, 2},
+}
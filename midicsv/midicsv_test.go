@@ -0,0 +1,150 @@
+package midicsv
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/steinarvk/midi"
+)
+
+func chunk(name, body string) []byte {
+	n := len(body)
+	return append([]byte(name), append([]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}, body...)...)
+}
+
+var (
+	testFileData = bytes.Join([][]byte{
+		chunk("MThd", "\x00\x01\x00\x02\x01\xe0"),
+		chunk("MTrk", "\x00\xff\x00\x02\x00\x07"+
+			"\x00\xff\x03\x0cSay \"hi\"\\\n\xc3\xa5"+
+			"\x00\xff\x02\x02\xe5\x01"+
+			"\x00\xff\x51\x03\x07\xa1\x20"+
+			"\x00\xff\x58\x04\x06\x03\x18\x08"+
+			"\x00\xff\x59\x02\xfd\x01"+
+			"\x00\xff\x54\x05\x60\x00\x03\x00\x00"+
+			"\x00\xff\x20\x01\x02"+
+			"\x00\xff\x21\x01\x00"+
+			"\x00\xff\x7f\x03\x00\x00\x41"+
+			"\x00\xff\x4b\x01\x09"+
+			"\x00\xff\x51\x02\x01\x02"+
+			"\x00\xff\x2f\x00"),
+		chunk("MTrk", "\x00\xc3\x49\x00\x93\x3c\x64\x83\x60\x3c\x00\x00\x83\x3e\x40\x00\xe3\x7f\x7f"+
+			"\x00\xb3\x07\x64\x00\xa3\x3e\x10\x00\xd3\x22\x00\xf0\x05\x7e\x7f\x09\x01\xf7"+
+			"\x00\xf7\x02\x43\xf7\x00\xff\x2f\x00"),
+	}, nil)
+)
+
+func TestRoundtrip(t *testing.T) {
+	f, err := midi.Parse(bytes.NewBuffer(testFileData))
+	if err != nil {
+		t.Fatalf("midi.Parse(..) = err: %v", err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := Write(buf, f); err != nil {
+		t.Fatalf("Write() = err: %v", err)
+	}
+
+	text := buf.String()
+	parsed, err := Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("Parse(%q) = err: %v", text, err)
+	}
+
+	if !reflect.DeepEqual(parsed, f) {
+		t.Errorf("Parse(Write(f)) = %v want %v; text:\n%s", parsed, f, text)
+	}
+}
+
+func TestRoundtripPaddedText(t *testing.T) {
+	f := &midi.File{
+		Header: &midi.Header{Format: 0, NumberOfTracks: 1, Division: 96},
+		Tracks: []*midi.Track{{Events: []midi.Event{
+			midi.MetaEvent{Type: metaTrackName, Data: []byte("  Lead  ")},
+			midi.MetaEvent{Type: metaLyric, Data: []byte(" la,")},
+			midi.MetaEvent{Type: metaEndOfTrack},
+		}}},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := Write(buf, f); err != nil {
+		t.Fatalf("Write() = err: %v", err)
+	}
+
+	text := buf.String()
+	parsed, err := Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("Parse(%q) = err: %v", text, err)
+	}
+
+	if !reflect.DeepEqual(parsed, f) {
+		t.Errorf("Parse(Write(f)) = %v want %v; text:\n%s", parsed, f, text)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	f, err := midi.Parse(bytes.NewBuffer(testFileData))
+	if err != nil {
+		t.Fatalf("midi.Parse(..) = err: %v", err)
+	}
+
+	want := "0, 0, Header, 1, 2, 480\n" +
+		"1, 0, Start_track\n" +
+		"1, 0, Sequence_number, 7\n" +
+		"1, 0, Title_t, \"Say \"\"hi\"\"\\\\\\012å\"\n" +
+		"1, 0, Copyright_t, \"\\345\\001\"\n" +
+		"1, 0, Tempo, 500000\n" +
+		"1, 0, Time_signature, 6, 3, 24, 8\n" +
+		"1, 0, Key_signature, -3, \"minor\"\n" +
+		"1, 0, SMPTE_offset, 96, 0, 3, 0, 0\n" +
+		"1, 0, Channel_prefix, 2\n" +
+		"1, 0, MIDI_port, 0\n" +
+		"1, 0, Sequencer_specific, 3, 0, 0, 65\n" +
+		"1, 0, Unknown_meta_event, 75, 1, 9\n" +
+		"1, 0, Unknown_meta_event, 81, 2, 1, 2\n" +
+		"1, 0, End_track\n" +
+		"2, 0, Start_track\n" +
+		"2, 0, Program_c, 3, 73\n" +
+		"2, 0, Note_on_c, 3, 60, 100\n" +
+		"2, 480, Note_on_c, 3, 60, 0\n" +
+		"2, 480, Note_off_c, 3, 62, 64\n" +
+		"2, 480, Pitch_bend_c, 3, 16383\n" +
+		"2, 480, Control_c, 3, 7, 100\n" +
+		"2, 480, Poly_aftertouch_c, 3, 62, 16\n" +
+		"2, 480, Channel_aftertouch_c, 3, 34\n" +
+		"2, 480, System_exclusive, 5, 126, 127, 9, 1, 247\n" +
+		"2, 480, System_exclusive_packet, 2, 67, 247\n" +
+		"2, 480, End_track\n" +
+		"0, 0, End_of_file\n"
+
+	buf := bytes.NewBuffer(nil)
+	if err := Write(buf, f); err != nil {
+		t.Fatalf("Write() = err: %v", err)
+	}
+
+	if got := buf.String(); got != want {
+		t.Errorf("Write() = %s want %s", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testcases := []string{
+		"1, 0, Start_track\n",
+		"0, 0, Header, 1, 1\n",
+		"0, 0, Header, 0, 1, 96\n1, 0, Note_on_c, 0, 60, 100\n",
+		"0, 0, Header, 0, 1, 96\n1, 0, Start_track\n1, 0, Note_on_c, 0, 60\n",
+		"0, 0, Header, 0, 1, 96\n1, 0, Start_track\n1, 0, Note_on_c, 0, 128, 1\n",
+		"0, 0, Header, 0, 1, 96\n1, 0, Start_track\n1, 10, Tempo, 1\n1, 5, Tempo, 1\n",
+		"0, 0, Header, 0, 1, 96\n1, 0, Start_track\n1, 0, System_exclusive, 3, 1, 2\n",
+		"0, 0, Header, 0, 1, 96\n1, 0, Start_track\n1, 0, Key_signature, 9, \"major\"\n",
+		"0, 0, Header, 0, 1, 96\n1, 0, Start_track\n1, 0, Bogus_event\n",
+	}
+
+	for _, text := range testcases {
+		if f, err := Parse(strings.NewReader(text)); err == nil {
+			t.Errorf("Parse(%q) = %v want error", text, f)
+		}
+	}
+}
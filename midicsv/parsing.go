@@ -0,0 +1,360 @@
+package midicsv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/steinarvk/midi"
+)
+
+type trackState struct {
+	track *midi.Track
+	now   int64
+}
+
+// Parse reads a file from midicsv records.
+func Parse(r io.Reader) (*midi.File, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.LazyQuotes = true
+
+	var f *midi.File
+	tracks := map[int]*trackState{}
+	sawEnd := false
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := cr.FieldPos(0)
+		wrap := func(err error) error {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+
+		// Padding inside quoted text is part of the text; anywhere else
+		// it is not.
+		text := len(record) > 3 && isTextRecord(strings.TrimSpace(record[2]))
+		for i := range record {
+			if i == 3 && text {
+				continue
+			}
+			record[i] = strings.TrimSpace(record[i])
+		}
+		if strings.HasPrefix(record[0], ";") {
+			continue
+		}
+		if len(record) < 3 {
+			return nil, wrap(fmt.Errorf("want at least 3 fields, got %d", len(record)))
+		}
+		if sawEnd {
+			return nil, wrap(errors.New("record after End_of_file"))
+		}
+
+		trackNo, err := strconv.Atoi(record[0])
+		if err != nil {
+			return nil, wrap(fmt.Errorf("invalid track number %q", record[0]))
+		}
+		t, err := strconv.ParseInt(record[1], 10, 64)
+		if err != nil || t < 0 {
+			return nil, wrap(fmt.Errorf("invalid time %q", record[1]))
+		}
+		kind, args := record[2], record[3:]
+
+		switch kind {
+		case "Header":
+			if f != nil {
+				return nil, wrap(errors.New("duplicate Header"))
+			}
+			if len(args) != 3 {
+				return nil, wrap(fmt.Errorf("Header: want 3 fields, got %d", len(args)))
+			}
+			var nums [3]int
+			for i, arg := range args {
+				if nums[i], err = strconv.Atoi(arg); err != nil {
+					return nil, wrap(fmt.Errorf("Header: invalid number %q", arg))
+				}
+			}
+			f = &midi.File{
+				Header: &midi.Header{
+					Format:         uint16(nums[0]),
+					NumberOfTracks: uint16(nums[1]),
+					Division:       int16(nums[2]),
+				},
+			}
+			continue
+
+		case "End_of_file":
+			sawEnd = true
+			continue
+
+		case "Start_track":
+			if f == nil {
+				return nil, wrap(errors.New("Start_track before Header"))
+			}
+			if tracks[trackNo] != nil {
+				return nil, wrap(fmt.Errorf("duplicate Start_track for track %d", trackNo))
+			}
+			trk := &midi.Track{}
+			f.Tracks = append(f.Tracks, trk)
+			tracks[trackNo] = &trackState{track: trk}
+			continue
+		}
+
+		state := tracks[trackNo]
+		if state == nil {
+			return nil, wrap(fmt.Errorf("%s in track %d without Start_track", kind, trackNo))
+		}
+
+		evt, err := parseEvent(kind, args)
+		if err != nil {
+			return nil, wrap(err)
+		}
+
+		if t < state.now {
+			return nil, wrap(fmt.Errorf("time %d is before previous event at %d", t, state.now))
+		}
+		if t > state.now {
+			state.track.Events = append(state.track.Events, midi.TimeDeltaEvent(t-state.now))
+			state.now = t
+		}
+		state.track.Events = append(state.track.Events, evt)
+	}
+
+	if f == nil {
+		return nil, errors.New("missing Header")
+	}
+
+	return f, nil
+}
+
+func parseEvent(kind string, args []string) (midi.Event, error) {
+	for _, rec := range channelRecords {
+		if rec.name != kind {
+			continue
+		}
+
+		if rec.status == 0xE0 {
+			nums, err := parseInts(args, 2, 2)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", kind, err)
+			}
+			if nums[0] > 15 || nums[1] > 0x3FFF {
+				return nil, fmt.Errorf("%s: value out of range in %v", kind, args)
+			}
+			return midi.NewMIDIEvent(rec.status|byte(nums[0]), []byte{byte(nums[1] & 0x7F), byte(nums[1] >> 7)})
+		}
+
+		nums, err := parseInts(args, rec.dataLen+1, rec.dataLen+1)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", kind, err)
+		}
+		if nums[0] > 15 {
+			return nil, fmt.Errorf("%s: invalid channel %d", kind, nums[0])
+		}
+		data, err := toBytes(nums[1:], 0x7F)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", kind, err)
+		}
+		return midi.NewMIDIEvent(rec.status|byte(nums[0]), data)
+	}
+
+	for metaType, name := range textRecords {
+		if name != kind {
+			continue
+		}
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s: want 1 field, got %d", kind, len(args))
+		}
+		text, err := unescape(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", kind, err)
+		}
+		return midi.MetaEvent{Type: metaType, Data: text}, nil
+	}
+
+	for metaType, rec := range numericRecords {
+		if rec.name != kind {
+			continue
+		}
+		nums, err := parseInts(args, rec.dataLen, rec.dataLen)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", kind, err)
+		}
+		data, err := toBytes(nums, 0xFF)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", kind, err)
+		}
+		return midi.MetaEvent{Type: metaType, Data: data}, nil
+	}
+
+	switch kind {
+	case "End_track":
+		return midi.MetaEvent{Type: metaEndOfTrack}, nil
+
+	case "Sequence_number":
+		nums, err := parseInts(args, 1, 1)
+		if err != nil || nums[0] > 0xFFFF {
+			return nil, fmt.Errorf("%s: invalid number %v", kind, args)
+		}
+		return midi.MetaEvent{Type: metaSequenceNumber, Data: []byte{byte(nums[0] >> 8), byte(nums[0])}}, nil
+
+	case "Tempo":
+		nums, err := parseInts(args, 1, 1)
+		if err != nil || nums[0] > 0xFFFFFF {
+			return nil, fmt.Errorf("%s: invalid tempo %v", kind, args)
+		}
+		return midi.MetaEvent{Type: metaTempo, Data: []byte{byte(nums[0] >> 16), byte(nums[0] >> 8), byte(nums[0])}}, nil
+
+	case "Key_signature":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s: want 2 fields, got %d", kind, len(args))
+		}
+		key, err := strconv.Atoi(args[0])
+		if err != nil || key < -7 || key > 7 {
+			return nil, fmt.Errorf("%s: invalid key %q", kind, args[0])
+		}
+		var minor byte
+		switch strings.ToLower(strings.Trim(args[1], `"`)) {
+		case "major":
+		case "minor":
+			minor = 1
+		default:
+			return nil, fmt.Errorf("%s: invalid mode %q", kind, args[1])
+		}
+		return midi.MetaEvent{Type: metaKeySignature, Data: []byte{byte(int8(key)), minor}}, nil
+
+	case "Sequencer_specific":
+		data, err := parseLengthPrefixed(args)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", kind, err)
+		}
+		return midi.MetaEvent{Type: metaSequencerSpecific, Data: data}, nil
+
+	case "Unknown_meta_event":
+		if len(args) < 1 {
+			return nil, fmt.Errorf("%s: missing type", kind)
+		}
+		metaType, err := strconv.ParseUint(args[0], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid type %q", kind, args[0])
+		}
+		data, err := parseLengthPrefixed(args[1:])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", kind, err)
+		}
+		return midi.MetaEvent{Type: byte(metaType), Data: data}, nil
+
+	case "System_exclusive", "System_exclusive_packet":
+		data, err := parseLengthPrefixed(args)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", kind, err)
+		}
+		var typeByte byte = 0xF0
+		if kind == "System_exclusive_packet" {
+			typeByte = 0xF7
+		}
+		return midi.SysexEvent(append([]byte{typeByte}, data...)), nil
+	}
+
+	return nil, fmt.Errorf("unknown record type %q", kind)
+}
+
+func isTextRecord(kind string) bool {
+	for _, name := range textRecords {
+		if name == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func parseInts(args []string, min, max int) ([]int, error) {
+	if len(args) < min || len(args) > max {
+		return nil, fmt.Errorf("want %d field(s), got %d", min, len(args))
+	}
+	var rv []int
+	for _, arg := range args {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid number %q", arg)
+		}
+		rv = append(rv, n)
+	}
+	return rv, nil
+}
+
+func toBytes(nums []int, max int) ([]byte, error) {
+	var rv []byte
+	for _, n := range nums {
+		if n > max {
+			return nil, fmt.Errorf("byte value %d out of range", n)
+		}
+		rv = append(rv, byte(n))
+	}
+	return rv, nil
+}
+
+func parseLengthPrefixed(args []string) ([]byte, error) {
+	if len(args) < 1 {
+		return nil, errors.New("missing length")
+	}
+	nums, err := parseInts(args, len(args), len(args))
+	if err != nil {
+		return nil, err
+	}
+	if nums[0] != len(nums)-1 {
+		return nil, fmt.Errorf("length %d does not match %d data byte(s)", nums[0], len(nums)-1)
+	}
+	data, err := toBytes(nums[1:], 0xFF)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// unescape undoes the backslash escapes of quote; the CSV reader has
+// already removed the quotes and undoubled embedded quotes.
+func unescape(s string) ([]byte, error) {
+	var rv []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			rv = append(rv, s[i])
+			continue
+		}
+		switch {
+		case i+1 < len(s) && s[i+1] == '\\':
+			rv = append(rv, '\\')
+			i++
+		case i+3 < len(s) && isOctal(s[i+1:i+4]):
+			n, _ := strconv.ParseUint(s[i+1:i+4], 8, 8)
+			rv = append(rv, byte(n))
+			i += 3
+		default:
+			return nil, fmt.Errorf("invalid escape in %q", s)
+		}
+	}
+	return rv, nil
+}
+
+func isOctal(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '7' {
+			return false
+		}
+	}
+	return s[0] <= '3'
+}
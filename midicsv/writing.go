@@ -0,0 +1,148 @@
+package midicsv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/steinarvk/midi"
+)
+
+// Write writes a file as midicsv records.
+func Write(w io.Writer, f *midi.File) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "0, 0, Header, %d, %d, %d\n", f.Header.Format, f.Header.NumberOfTracks, f.Header.Division)
+
+	for i, trk := range f.Tracks {
+		trackNo := i + 1
+		fmt.Fprintf(bw, "%d, 0, Start_track\n", trackNo)
+
+		var now int64
+		for j, evt := range trk.Events {
+			if td, ok := evt.(midi.TimeDeltaEvent); ok {
+				now += int64(td)
+				continue
+			}
+
+			fields, err := formatEvent(evt)
+			if err != nil {
+				return fmt.Errorf("track #%d event #%d: %v", i, j, err)
+			}
+
+			fmt.Fprintf(bw, "%d, %d, %s\n", trackNo, now, strings.Join(fields, ", "))
+		}
+	}
+
+	fmt.Fprintf(bw, "0, 0, End_of_file\n")
+
+	return bw.Flush()
+}
+
+func formatEvent(evt midi.Event) ([]string, error) {
+	switch v := evt.(type) {
+	case midi.MIDIEvent:
+		status := byte(v.Status())
+		data := v.Data()
+		for _, rec := range channelRecords {
+			if rec.status != status {
+				continue
+			}
+			if len(data) != rec.dataLen {
+				return nil, fmt.Errorf("%s: want %d data byte(s), got %v", rec.name, rec.dataLen, data)
+			}
+			fields := []string{rec.name, strconv.Itoa(v.Channel)}
+			if status == 0xE0 {
+				return append(fields, strconv.Itoa(int(data[0])|int(data[1])<<7)), nil
+			}
+			return append(fields, formatBytes(data)...), nil
+		}
+		return nil, fmt.Errorf("unknown MIDI event type %02x", status)
+
+	case midi.MetaEvent:
+		return formatMetaEvent(v), nil
+
+	case midi.SysexEvent:
+		if len(v) == 0 {
+			return nil, fmt.Errorf("empty SysexEvent")
+		}
+		name := "System_exclusive"
+		if v[0] == 0xF7 {
+			name = "System_exclusive_packet"
+		}
+		return append([]string{name, strconv.Itoa(len(v) - 1)}, formatBytes(v[1:])...), nil
+
+	default:
+		return nil, fmt.Errorf("unable to format event %v", evt)
+	}
+}
+
+func formatMetaEvent(e midi.MetaEvent) []string {
+	data := e.Data
+
+	if name, ok := textRecords[e.Type]; ok {
+		return []string{name, quote(data)}
+	}
+
+	if rec, ok := numericRecords[e.Type]; ok && len(data) == rec.dataLen {
+		return append([]string{rec.name}, formatBytes(data)...)
+	}
+
+	switch {
+	case e.Type == metaEndOfTrack && len(data) == 0:
+		return []string{"End_track"}
+
+	case e.Type == metaSequenceNumber && len(data) == 2:
+		return []string{"Sequence_number", strconv.Itoa(int(data[0])<<8 | int(data[1]))}
+
+	case e.Type == metaTempo && len(data) == 3:
+		return []string{"Tempo", strconv.Itoa(int(data[0])<<16 | int(data[1])<<8 | int(data[2]))}
+
+	case e.Type == metaKeySignature && len(data) == 2 && data[1] <= 1 && int8(data[0]) >= -7 && int8(data[0]) <= 7:
+		mode := `"major"`
+		if data[1] == 1 {
+			mode = `"minor"`
+		}
+		return []string{"Key_signature", strconv.Itoa(int(int8(data[0]))), mode}
+
+	case e.Type == metaSequencerSpecific:
+		return append([]string{"Sequencer_specific", strconv.Itoa(len(data))}, formatBytes(data)...)
+	}
+
+	return append([]string{"Unknown_meta_event", strconv.Itoa(int(e.Type)), strconv.Itoa(len(data))}, formatBytes(data)...)
+}
+
+func formatBytes(data []byte) []string {
+	var rv []string
+	for _, b := range data {
+		rv = append(rv, strconv.Itoa(int(b)))
+	}
+	return rv
+}
+
+// quote quotes text the way midicsv does: quotes are doubled, backslashes
+// are escaped, and control characters are written as octal escapes. Text
+// that is not valid UTF-8 has its non-ASCII bytes escaped too.
+func quote(data []byte) string {
+	escapeHigh := !utf8.Valid(data)
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, b := range data {
+		switch {
+		case b == '"':
+			sb.WriteString(`""`)
+		case b == '\\':
+			sb.WriteString(`\\`)
+		case b < 0x20 || b == 0x7F || (b >= 0x80 && escapeHigh):
+			fmt.Fprintf(&sb, "\\%03o", b)
+		default:
+			sb.WriteByte(b)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
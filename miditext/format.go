@@ -0,0 +1,50 @@
+// Package miditext reads and writes MIDI files as line-oriented text,
+// suitable for keeping fixtures in version control as diffable files.
+//
+// A file consists of a header line followed by tracks. Each track begins
+// with an MTrk line and is followed by one line per event, prefixed by
+// its time: "+N" for N ticks after the previous event in the track, or
+// "@N" for N ticks after the start of the track. Blank lines, and lines
+// beginning with '#', are ignored.
+//
+//	MThd format=1 tracks=2 division=96
+//	MTrk
+//	+0 Meta TrackName "Melody"
+//	+0 Meta TempoSetting 07 a1 20
+//	MTrk
+//	@0 ProgramChange ch=0 num=73
+//	@0 NoteOn ch=0 key=C4 vel=100
+//	@96 NoteOff ch=0 key=C4 vel=64
+//	@96 PitchBend ch=0 val=8192
+//	@192 SysEx f0 7e 7f 09 01 f7
+//	@192 Meta EndOfTrack
+//
+// Channel messages are NoteOff, NoteOn, Aftertouch, ControlChange,
+// ProgramChange, ChannelPressure and PitchBend, with their fields given
+// as decimal key=value pairs. Keys may also be given as note names.
+// PitchBend values are 14-bit, centered on 8192.
+//
+// Meta events are named by type, or given as a hex byte such as 0x4b for
+// unknown types. Their data is a quoted Go string for text events, and
+// hex bytes otherwise. SysEx events list all their bytes in hex, starting
+// with the f0 or f7 byte.
+//
+// A line with a time but no event advances time without an event.
+package miditext
+
+type channelMessage struct {
+	name    string
+	status  byte
+	dataLen int
+	fields  []string
+}
+
+var channelMessages = []channelMessage{
+	{"NoteOff", 0x80, 2, []string{"key", "vel"}},
+	{"NoteOn", 0x90, 2, []string{"key", "vel"}},
+	{"Aftertouch", 0xA0, 2, []string{"key", "val"}},
+	{"ControlChange", 0xB0, 2, []string{"num", "val"}},
+	{"ProgramChange", 0xC0, 1, []string{"num"}},
+	{"ChannelPressure", 0xD0, 1, []string{"val"}},
+	{"PitchBend", 0xE0, 2, []string{"val"}},
+}
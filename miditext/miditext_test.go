@@ -0,0 +1,143 @@
+package miditext
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/steinarvk/midi"
+)
+
+func chunk(name, body string) []byte {
+	n := len(body)
+	return append([]byte(name), append([]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}, body...)...)
+}
+
+var (
+	testFileData = bytes.Join([][]byte{
+		chunk("MThd", "\x00\x01\x00\x02\x00\x60"),
+		chunk("MTrk", "\x00\xff\x03\x06Melody\x00\xff\x51\x03\x07\xa1\x20\x00\xff\x2f\x00"),
+		chunk("MTrk", "\x00\xc0\x49\x00\x90\x3c\x64\x60\x3c\x00\x00\x80\x3e\x40\x00\xe0\x00\x40"+
+			"\x00\xb0\x07\x64\x00\xf0\x05\x7e\x7f\x09\x01\xf7\x10\xff\x7f\x02\x00\x01\x00\xff\x2f\x00"),
+	}, nil)
+)
+
+func TestRoundtrip(t *testing.T) {
+	f, err := midi.Parse(bytes.NewBuffer(testFileData))
+	if err != nil {
+		t.Fatalf("midi.Parse(..) = err: %v", err)
+	}
+
+	for _, opts := range []Options{{}, {AbsoluteTicks: true}} {
+		buf := bytes.NewBuffer(nil)
+		if err := Write(buf, f, opts); err != nil {
+			t.Errorf("Write(%+v) = err: %v", opts, err)
+			continue
+		}
+
+		text := buf.String()
+		parsed, err := Parse(strings.NewReader(text))
+		if err != nil {
+			t.Errorf("Parse(%q) = err: %v", text, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(parsed, f) {
+			t.Errorf("Parse(Write(%+v)) = %v want %v; text:\n%s", opts, parsed, f, text)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	f, err := midi.Parse(bytes.NewBuffer(testFileData))
+	if err != nil {
+		t.Fatalf("midi.Parse(..) = err: %v", err)
+	}
+
+	want := `MThd format=1 tracks=2 division=96
+MTrk
++0 Meta TrackName "Melody"
++0 Meta TempoSetting 07 a1 20
++0 Meta EndOfTrack
+MTrk
++0 ProgramChange ch=0 num=73
++0 NoteOn ch=0 key=C4 vel=100
++96 NoteOn ch=0 key=C4 vel=0
++0 NoteOff ch=0 key=D4 vel=64
++0 PitchBend ch=0 val=8192
++0 ControlChange ch=0 num=7 val=100
++0 SysEx f0 7e 7f 09 01 f7
++16 Meta SequencerSpecificEvent 00 01
++0 Meta EndOfTrack
+`
+
+	buf := bytes.NewBuffer(nil)
+	if err := Write(buf, f, Options{}); err != nil {
+		t.Fatalf("Write() = err: %v", err)
+	}
+
+	if got := buf.String(); got != want {
+		t.Errorf("Write() = %s want %s", got, want)
+	}
+}
+
+func TestTextToMIDI(t *testing.T) {
+	text := `MThd format=1 tracks=2 division=96
+MTrk
++0 Meta TrackName "Melody"
++0 Meta EndOfTrack
+MTrk
++0 ProgramChange ch=0 num=73
++0 NoteOn ch=0 key=C4 vel=100
++96 NoteOff ch=0 key=C4 vel=64
++0 PitchBend ch=0 val=8192
++0 SysEx f0 7e 7f 09 01 f7
++0 Meta EndOfTrack
+`
+
+	f, err := Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("Parse(%q) = err: %v", text, err)
+	}
+
+	data := bytes.NewBuffer(nil)
+	if err := f.Write(data); err != nil {
+		t.Fatalf("f.Write() = err: %v", err)
+	}
+
+	parsed, err := midi.Parse(data)
+	if err != nil {
+		t.Fatalf("midi.Parse(f.Write()) = err: %v", err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := Write(buf, parsed, Options{}); err != nil {
+		t.Fatalf("Write() = err: %v", err)
+	}
+
+	if got := buf.String(); got != text {
+		t.Errorf("Write(midi.Parse(f.Write())) = %s want %s", got, text)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testcases := []string{
+		"MTrk\n",
+		"MThd format=0\n",
+		"MThd division=96\n+0 NoteOn ch=0 key=60 vel=1\n",
+		"MThd division=96\nMTrk\n+0 NoteOn ch=0 key=60\n",
+		"MThd division=96\nMTrk\n+0 NoteOn ch=16 key=60 vel=1\n",
+		"MThd division=96\nMTrk\n+0 NoteOn ch=0 key=H4 vel=1\n",
+		"MThd division=96\nMTrk\n@10\n@5 NoteOn ch=0 key=60 vel=1\n",
+		"MThd division=96\nMTrk\n+0 SysEx 01 02\n",
+		"MThd division=96\nMTrk\n+0 Meta Bogus 01\n",
+		"MThd division=96\nMTrk\n10 Meta EndOfTrack\n",
+	}
+
+	for _, text := range testcases {
+		if f, err := Parse(strings.NewReader(text)); err == nil {
+			t.Errorf("Parse(%q) = %v want error", text, f)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package miditext
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/steinarvk/midi"
+	"github.com/steinarvk/midi/pitch"
+)
+
+// Parse reads a file in text form.
+func Parse(r io.Reader) (*midi.File, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+
+	var f *midi.File
+	var trk *midi.Track
+	var now int64
+	numberOfTracks := -1
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		wrap := func(err error) error {
+			return fmt.Errorf("line %d: %v", lineNo, err)
+		}
+
+		fields := strings.Fields(line)
+		switch {
+		case fields[0] == "MThd":
+			if f != nil {
+				return nil, wrap(errors.New("duplicate MThd"))
+			}
+			hdr, n, err := parseHeader(fields[1:])
+			if err != nil {
+				return nil, wrap(err)
+			}
+			f = &midi.File{Header: hdr}
+			numberOfTracks = n
+
+		case fields[0] == "MTrk":
+			if f == nil {
+				return nil, wrap(errors.New("MTrk before MThd"))
+			}
+			if len(fields) != 1 {
+				return nil, wrap(fmt.Errorf("unexpected %q after MTrk", fields[1:]))
+			}
+			trk = &midi.Track{}
+			f.Tracks = append(f.Tracks, trk)
+			now = 0
+
+		default:
+			if trk == nil {
+				return nil, wrap(errors.New("event outside of track"))
+			}
+
+			timeField := fields[0]
+			ticks, err := strconv.ParseInt(timeField[1:], 10, 64)
+			if err != nil || ticks < 0 {
+				return nil, wrap(fmt.Errorf("invalid time %q", timeField))
+			}
+
+			var delta int64
+			switch timeField[0] {
+			case '+':
+				delta = ticks
+			case '@':
+				delta = ticks - now
+				if delta < 0 {
+					return nil, wrap(fmt.Errorf("time %q is before previous event at @%d", timeField, now))
+				}
+			default:
+				return nil, wrap(fmt.Errorf("invalid time %q: want +N or @N", timeField))
+			}
+
+			if delta > 0 {
+				trk.Events = append(trk.Events, midi.TimeDeltaEvent(delta))
+				now += delta
+			}
+
+			rest := strings.TrimSpace(line[len(timeField):])
+			if rest == "" {
+				continue
+			}
+
+			evt, err := ParseEvent(rest)
+			if err != nil {
+				return nil, wrap(err)
+			}
+			trk.Events = append(trk.Events, evt)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if f == nil {
+		return nil, errors.New("missing MThd")
+	}
+
+	if numberOfTracks < 0 {
+		numberOfTracks = len(f.Tracks)
+	}
+	f.Header.NumberOfTracks = uint16(numberOfTracks)
+
+	return f, nil
+}
+
+func parseHeader(fields []string) (*midi.Header, int, error) {
+	hdr := &midi.Header{}
+	numberOfTracks := -1
+	sawDivision := false
+
+	for _, field := range fields {
+		k, v, err := splitField(field)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid %s %q", k, v)
+		}
+
+		switch k {
+		case "format":
+			hdr.Format = uint16(n)
+		case "tracks":
+			numberOfTracks = int(n)
+		case "division":
+			hdr.Division = int16(n)
+			sawDivision = true
+		default:
+			return nil, 0, fmt.Errorf("unknown header field %q", k)
+		}
+	}
+
+	if !sawDivision {
+		return nil, 0, errors.New("missing division in MThd")
+	}
+
+	return hdr, numberOfTracks, nil
+}
+
+func splitField(field string) (string, string, error) {
+	i := strings.Index(field, "=")
+	if i < 0 {
+		return "", "", fmt.Errorf("invalid field %q: want key=value", field)
+	}
+	return field[:i], field[i+1:], nil
+}
+
+// ParseEvent parses a single event, without its time.
+func ParseEvent(s string) (midi.Event, error) {
+	s = strings.TrimSpace(s)
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, errors.New("empty event")
+	}
+
+	switch fields[0] {
+	case "Meta":
+		return parseMetaEvent(s, fields)
+
+	case "SysEx":
+		data, err := parseHex(fields[1:])
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 || (data[0] != 0xF0 && data[0] != 0xF7) {
+			return nil, fmt.Errorf("SysEx must begin with f0 or f7: %q", s)
+		}
+		return midi.SysexEvent(data), nil
+	}
+
+	for _, msg := range channelMessages {
+		if msg.name == fields[0] {
+			return parseMIDIEvent(msg, fields[1:])
+		}
+	}
+
+	return nil, fmt.Errorf("unknown event %q", fields[0])
+}
+
+func parseMetaEvent(s string, fields []string) (midi.Event, error) {
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("missing meta event type in %q", s)
+	}
+
+	metaType, ok := midi.MetaEventType(fields[1])
+	if !ok {
+		n, err := strconv.ParseUint(fields[1], 0, 8)
+		if err != nil {
+			return nil, fmt.Errorf("unknown meta event type %q", fields[1])
+		}
+		metaType = byte(n)
+	}
+
+	rest := strings.TrimSpace(strings.TrimSpace(s[len("Meta"):])[len(fields[1]):])
+	if strings.HasPrefix(rest, "\"") {
+		text, err := strconv.Unquote(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid meta event text %s: %v", rest, err)
+		}
+		return midi.MetaEvent{Type: metaType, Data: []byte(text)}, nil
+	}
+
+	data, err := parseHex(fields[2:])
+	if err != nil {
+		return nil, err
+	}
+	return midi.MetaEvent{Type: metaType, Data: data}, nil
+}
+
+func parseMIDIEvent(msg channelMessage, fields []string) (midi.Event, error) {
+	values := map[string]string{}
+	for _, field := range fields {
+		k, v, err := splitField(field)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := values[k]; dup {
+			return nil, fmt.Errorf("%s: duplicate field %q", msg.name, k)
+		}
+		values[k] = v
+	}
+
+	intField := func(name string, max int) (int, error) {
+		v, ok := values[name]
+		if !ok {
+			return 0, fmt.Errorf("%s: missing field %q", msg.name, name)
+		}
+		delete(values, name)
+
+		var n int
+		var err error
+		if name == "key" {
+			n, err = parseKey(v)
+		} else {
+			n, err = strconv.Atoi(v)
+		}
+		if err != nil || n < 0 || n > max {
+			return 0, fmt.Errorf("%s: invalid %s %q", msg.name, name, v)
+		}
+		return n, nil
+	}
+
+	channel, err := intField("ch", 15)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if msg.status == 0xE0 {
+		val, err := intField("val", 0x3FFF)
+		if err != nil {
+			return nil, err
+		}
+		data = []byte{byte(val & 0x7F), byte(val >> 7)}
+	} else {
+		for _, name := range msg.fields {
+			n, err := intField(name, 0x7F)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, byte(n))
+		}
+	}
+
+	for k := range values {
+		return nil, fmt.Errorf("%s: unknown field %q", msg.name, k)
+	}
+
+	return midi.NewMIDIEvent(msg.status|byte(channel), data)
+}
+
+func parseKey(s string) (int, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, nil
+	}
+	return pitch.Parse(s)
+}
+
+func parseHex(fields []string) ([]byte, error) {
+	var rv []byte
+	for _, field := range fields {
+		b, err := hex.DecodeString(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex data %q: %v", field, err)
+		}
+		rv = append(rv, b...)
+	}
+	return rv, nil
+}
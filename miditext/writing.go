@@ -0,0 +1,122 @@
+package miditext
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/steinarvk/midi"
+	"github.com/steinarvk/midi/pitch"
+)
+
+type Options struct {
+	// AbsoluteTicks writes times relative to the start of the track
+	// rather than to the previous event.
+	AbsoluteTicks bool
+}
+
+// Write writes a file in text form.
+func Write(w io.Writer, f *midi.File, opts Options) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "MThd format=%d tracks=%d division=%d\n", f.Header.Format, f.Header.NumberOfTracks, f.Header.Division)
+
+	for i, trk := range f.Tracks {
+		fmt.Fprintf(bw, "MTrk\n")
+
+		var now, delta int64
+		timePrefix := func() string {
+			if opts.AbsoluteTicks {
+				return fmt.Sprintf("@%d", now)
+			}
+			return fmt.Sprintf("+%d", delta)
+		}
+
+		for j, evt := range trk.Events {
+			if td, ok := evt.(midi.TimeDeltaEvent); ok {
+				now += int64(td)
+				delta += int64(td)
+				continue
+			}
+
+			line, err := FormatEvent(evt)
+			if err != nil {
+				return fmt.Errorf("track #%d event #%d: %v", i, j, err)
+			}
+
+			fmt.Fprintf(bw, "%s %s\n", timePrefix(), line)
+			delta = 0
+		}
+
+		if delta > 0 {
+			fmt.Fprintf(bw, "%s\n", timePrefix())
+		}
+	}
+
+	return bw.Flush()
+}
+
+// FormatEvent formats a single event, without its time.
+func FormatEvent(evt midi.Event) (string, error) {
+	switch v := evt.(type) {
+	case midi.MIDIEvent:
+		return formatMIDIEvent(v)
+
+	case midi.MetaEvent:
+		name, ok := midi.MetaEventName(v.Type)
+		if !ok {
+			name = fmt.Sprintf("0x%02x", v.Type)
+		}
+		switch {
+		case len(v.Data) == 0:
+			return "Meta " + name, nil
+		case v.IsText():
+			return "Meta " + name + " " + strconv.Quote(string(v.Data)), nil
+		default:
+			return "Meta " + name + " " + formatHex(v.Data), nil
+		}
+
+	case midi.SysexEvent:
+		return "SysEx " + formatHex(v), nil
+
+	default:
+		return "", fmt.Errorf("unable to format event %v", evt)
+	}
+}
+
+func formatMIDIEvent(e midi.MIDIEvent) (string, error) {
+	status := byte(e.Status())
+	data := e.Data()
+
+	for _, msg := range channelMessages {
+		if msg.status != status {
+			continue
+		}
+		if len(data) != msg.dataLen {
+			return "", fmt.Errorf("%s: want %d data byte(s), got %v", msg.name, msg.dataLen, data)
+		}
+
+		parts := []string{msg.name, fmt.Sprintf("ch=%d", e.Channel)}
+		if status == 0xE0 {
+			parts = append(parts, fmt.Sprintf("val=%d", int(data[0])|int(data[1])<<7))
+			return strings.Join(parts, " "), nil
+		}
+
+		for i, field := range msg.fields {
+			if field == "key" {
+				parts = append(parts, "key="+pitch.Name(int(data[i])))
+			} else {
+				parts = append(parts, fmt.Sprintf("%s=%d", field, data[i]))
+			}
+		}
+		return strings.Join(parts, " "), nil
+	}
+
+	return "", fmt.Errorf("unknown MIDI event type %02x", status)
+}
+
+func formatHex(data []byte) string {
+	return fmt.Sprintf("% 02x", data)
+}
@@ -0,0 +1,435 @@
+// Package mml compiles Music Macro Language, as used for chiptunes, into
+// MIDI files.
+//
+// The input is a sequence of channels separated by semicolons, each a
+// sequence of commands (case is ignored, as is whitespace):
+//
+//	c d e f g a b   notes, with + or # for sharp and - for flat, then an
+//	                optional length and dots, as in "c+8."
+//	n60             a note by MIDI key, with an optional comma and length
+//	r p             a rest, with an optional length and dots
+//	& ^8            tie to the next note; extend the last note or rest
+//	o4 > <          set the octave (o4 c is middle C); up and down one
+//	l8              set the default length, possibly dotted
+//	t120            set the tempo in quarter notes per minute
+//	v12             set the volume from 0 to 15
+//	@40             select a program
+//	[cde:fg]3       repeat three times, leaving after the colon on the last
+//	                time round; the count defaults to 2
+//
+// Lengths are note values: 4 is a quarter note, 8 an eighth note, 12 an
+// eighth-note triplet and so on. Comments run from // to the end of the
+// line.
+package mml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+
+	"github.com/steinarvk/midi"
+)
+
+const (
+	DefaultDivision = 480
+	DefaultVelocity = 100
+	DefaultOctave   = 4
+)
+
+// maxCommands bounds the number of commands a channel may run, counting
+// each time round a loop, so that nested loops cannot run for ever.
+const maxCommands = 1 << 20
+
+var pitchClasses = map[byte]int{
+	'c': 0,
+	'd': 2,
+	'e': 4,
+	'f': 5,
+	'g': 7,
+	'a': 9,
+	'b': 11,
+}
+
+// Parse compiles MML into a format 1 file with a conductor track holding
+// the tempo, followed by a track for each channel. Channels are given
+// MIDI channels in order, skipping the percussion channel.
+func Parse(r io.Reader) (*midi.File, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		lines = append(lines, line)
+	}
+	source := strings.ToLower(strings.Join(lines, "\n"))
+
+	conductor := midi.NewSimpleWriter(DefaultDivision)
+	tempos := map[int]bool{}
+	end := 0
+	rv := &midi.File{
+		Header: &midi.Header{Format: 1, Division: DefaultDivision},
+		Tracks: []*midi.Track{nil},
+	}
+
+	for _, src := range strings.Split(source, ";") {
+		if strings.TrimSpace(src) == "" {
+			continue
+		}
+
+		c := &channel{
+			src:       src,
+			writer:    midi.NewSimpleWriter(DefaultDivision),
+			conductor: conductor,
+			tempos:    tempos,
+			channel:   midiChannel(len(rv.Tracks) - 1),
+			octave:    DefaultOctave,
+			length:    DefaultDivision,
+			velocity:  DefaultVelocity,
+		}
+		if err := c.run(0, len(src)); err != nil {
+			return nil, fmt.Errorf("channel %d: %v", len(rv.Tracks), err)
+		}
+		c.flush()
+		c.writer.EventAt(c.now, midi.MetaEvent{Type: midi.EndOfTrack})
+		if c.now > end {
+			end = c.now
+		}
+
+		rv.Tracks = append(rv.Tracks, c.writer.File().Tracks[0])
+	}
+
+	if len(rv.Tracks) == 1 {
+		return nil, errors.New("no channels found")
+	}
+
+	conductor.EventAt(end, midi.MetaEvent{Type: midi.EndOfTrack})
+	rv.Tracks[0] = conductor.File().Tracks[0]
+	rv.Header.NumberOfTracks = uint16(len(rv.Tracks))
+	return rv, nil
+}
+
+func midiChannel(n int) int {
+	if n >= 9 {
+		n++
+	}
+	return n % 16
+}
+
+type pendingNote struct {
+	key, velocity int
+	start, end    int
+}
+
+// channel compiles the commands of one channel. The note last played is
+// held back until it is known whether it is tied to what follows.
+type channel struct {
+	src       string
+	writer    *midi.SimpleWriter
+	conductor *midi.SimpleWriter
+	tempos    map[int]bool
+
+	channel  int
+	octave   int
+	length   int
+	velocity int
+	now      int
+	loops    int
+	commands int
+
+	pending  *pendingNote
+	tie      bool
+	lastRest bool
+}
+
+func (c *channel) errorAt(i int, format string, args ...interface{}) error {
+	return fmt.Errorf("offset %d: %s", i, fmt.Sprintf(format, args...))
+}
+
+func (c *channel) run(start, end int) error {
+	i := start
+	for i < end {
+		if err := c.count(i); err != nil {
+			return err
+		}
+		cmd := c.src[i]
+		var err error
+
+		switch {
+		case cmd == ' ' || cmd == '\t' || cmd == '\n' || cmd == '\r':
+			i++
+		case strings.IndexByte("cdefgab", cmd) >= 0:
+			i, err = c.note(i)
+		case cmd == 'n':
+			i, err = c.keyNote(i)
+		case cmd == 'r' || cmd == 'p':
+			var ticks int
+			ticks, i, err = c.readLength(i+1, c.length)
+			c.now += ticks
+			c.lastRest = true
+			c.tie = false
+		case cmd == '&':
+			c.tie = true
+			i++
+		case cmd == '^':
+			i, err = c.extend(i)
+		case cmd == 'o':
+			var n int
+			n, i, err = c.readNumber(i + 1)
+			if err == nil && n > 9 {
+				err = c.errorAt(i, "octave %d out of range", n)
+			}
+			c.octave = n
+		case cmd == '>':
+			c.octave++
+			i++
+		case cmd == '<':
+			c.octave--
+			i++
+		case cmd == 'l':
+			c.length, i, err = c.readLength(i+1, 0)
+		case cmd == 't':
+			i, err = c.tempo(i)
+		case cmd == 'v':
+			var n int
+			n, i, err = c.readNumber(i + 1)
+			if err == nil && n > 15 {
+				err = c.errorAt(i, "volume %d out of range", n)
+			}
+			c.velocity = n * 127 / 15
+		case cmd == '@':
+			var n int
+			n, i, err = c.readNumber(i + 1)
+			if err == nil && n > 127 {
+				err = c.errorAt(i, "program %d out of range", n)
+			}
+			c.writer.EventAt(c.now, midi.MIDIEvent{Type: midi.ProgramChange, Channel: c.channel, ProgramNumber: n})
+		case cmd == '[':
+			i, err = c.loop(i)
+		case cmd == ':' && c.loops > 0:
+			i++
+		default:
+			err = c.errorAt(i, "unexpected %q", cmd)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// count counts a command, failing once there have been too many.
+func (c *channel) count(i int) error {
+	c.commands++
+	if c.commands > maxCommands {
+		return c.errorAt(i, "more than %d commands; loops repeat too often", maxCommands)
+	}
+	return nil
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func (c *channel) readNumber(i int) (int, int, error) {
+	start := i
+	n := 0
+	for i < len(c.src) && isDigit(c.src[i]) {
+		n = n*10 + int(c.src[i]-'0')
+		if n > 1<<20 {
+			return 0, i, c.errorAt(start, "number too large")
+		}
+		i++
+	}
+	if i == start {
+		return 0, i, c.errorAt(i, "missing number")
+	}
+	return n, i, nil
+}
+
+// readLength reads an optional note value and dots, returning the length
+// in ticks. Without a note value the length is def, and if def is zero the
+// note value is required.
+func (c *channel) readLength(i, def int) (int, int, error) {
+	ticks := def
+	if def == 0 || (i < len(c.src) && isDigit(c.src[i])) {
+		start := i
+		n, next, err := c.readNumber(i)
+		if err != nil {
+			return 0, next, err
+		}
+		if n == 0 || (4*DefaultDivision)%n != 0 {
+			return 0, next, c.errorAt(start, "unsupported length %d", n)
+		}
+		ticks, i = 4*DefaultDivision/n, next
+	}
+
+	dot := ticks
+	for i < len(c.src) && c.src[i] == '.' {
+		if dot%2 != 0 {
+			return 0, i, c.errorAt(i, "too many dots")
+		}
+		dot /= 2
+		ticks += dot
+		i++
+	}
+	return ticks, i, nil
+}
+
+func (c *channel) note(i int) (int, error) {
+	start := i
+	key := (c.octave+1)*12 + pitchClasses[c.src[i]]
+	for i++; i < len(c.src); i++ {
+		if c.src[i] == '+' || c.src[i] == '#' {
+			key++
+		} else if c.src[i] == '-' {
+			key--
+		} else {
+			break
+		}
+	}
+
+	ticks, i, err := c.readLength(i, c.length)
+	if err != nil {
+		return i, err
+	}
+	return i, c.play(start, key, ticks)
+}
+
+func (c *channel) keyNote(i int) (int, error) {
+	start := i
+	key, i, err := c.readNumber(i + 1)
+	if err != nil {
+		return i, err
+	}
+
+	ticks := c.length
+	if i < len(c.src) && c.src[i] == ',' {
+		ticks, i, err = c.readLength(i+1, 0)
+		if err != nil {
+			return i, err
+		}
+	}
+	return i, c.play(start, key, ticks)
+}
+
+func (c *channel) play(i, key, ticks int) error {
+	if key < 0 || key > 127 {
+		return c.errorAt(i, "note out of range")
+	}
+
+	if c.tie && c.pending != nil && c.pending.key == key && c.pending.end == c.now {
+		c.pending.end += ticks
+	} else {
+		c.flush()
+		c.pending = &pendingNote{key: key, velocity: c.velocity, start: c.now, end: c.now + ticks}
+	}
+
+	c.now += ticks
+	c.tie = false
+	c.lastRest = false
+	return nil
+}
+
+func (c *channel) extend(i int) (int, error) {
+	start := i
+	ticks, i, err := c.readLength(i+1, c.length)
+	if err != nil {
+		return i, err
+	}
+
+	switch {
+	case c.lastRest:
+	case c.pending != nil && c.pending.end == c.now:
+		c.pending.end += ticks
+	default:
+		return i, c.errorAt(start, "^ without preceding note or rest")
+	}
+	c.now += ticks
+	return i, nil
+}
+
+func (c *channel) flush() {
+	if c.pending == nil {
+		return
+	}
+	n := c.pending
+	c.writer.EventAt(n.start, midi.MIDIEvent{Type: midi.NoteOn, Channel: c.channel, Key: n.key, Velocity: n.velocity})
+	c.writer.EventAt(n.end, midi.MIDIEvent{Type: midi.NoteOff, Channel: c.channel, Key: n.key, Velocity: n.velocity})
+	c.pending = nil
+}
+
+func (c *channel) tempo(i int) (int, error) {
+	start := i
+	bpm, i, err := c.readNumber(i + 1)
+	if err != nil {
+		return i, err
+	}
+	if bpm == 0 {
+		return i, c.errorAt(start, "tempo must be positive")
+	}
+
+	if !c.tempos[c.now] {
+		c.tempos[c.now] = true
+		c.conductor.EventAt(c.now, midi.NewTempoEvent(int64(math.Floor(60e6/float64(bpm)+0.5))))
+	}
+	return i, nil
+}
+
+// loop plays the body of a loop as many times as the count following it,
+// stopping at the first colon in the body on the last time round.
+func (c *channel) loop(i int) (int, error) {
+	start := i
+	depth, colon, end := 0, -1, -1
+	for j := i + 1; j < len(c.src) && end < 0; j++ {
+		switch c.src[j] {
+		case '[':
+			depth++
+		case ']':
+			if depth == 0 {
+				end = j
+			}
+			depth--
+		case ':':
+			if depth == 0 && colon < 0 {
+				colon = j
+			}
+		}
+	}
+	if end < 0 {
+		return i, c.errorAt(start, "unterminated loop")
+	}
+
+	count, next := 2, end+1
+	if next < len(c.src) && isDigit(c.src[next]) {
+		var err error
+		count, next, err = c.readNumber(next)
+		if err != nil {
+			return next, err
+		}
+	}
+
+	c.loops++
+	defer func() { c.loops-- }()
+	for k := 0; k < count; k++ {
+		if err := c.count(start); err != nil {
+			return next, err
+		}
+		bodyEnd := end
+		if k == count-1 && colon >= 0 {
+			bodyEnd = colon
+		}
+		if err := c.run(start+1, bodyEnd); err != nil {
+			return next, err
+		}
+	}
+	return next, nil
+}
@@ -0,0 +1,159 @@
+package mml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/steinarvk/midi"
+)
+
+func note(channel, key, velocity int, start, duration int64) midi.Note {
+	return midi.Note{Channel: channel, Key: key, Velocity: velocity, Start: start, Duration: duration}
+}
+
+func TestParse(t *testing.T) {
+	testcases := []struct {
+		source string
+		want   []midi.Note
+	}{
+		{
+			"t120 o4 l8 cdefgab>c",
+			[]midi.Note{
+				note(0, 60, 100, 0, 240),
+				note(0, 62, 100, 240, 240),
+				note(0, 64, 100, 480, 240),
+				note(0, 65, 100, 720, 240),
+				note(0, 67, 100, 960, 240),
+				note(0, 69, 100, 1200, 240),
+				note(0, 71, 100, 1440, 240),
+				note(0, 72, 100, 1680, 240),
+			},
+		},
+		{
+			"l4 c. d8 & d4 r8 e^8 v15 f+ <b-16",
+			[]midi.Note{
+				note(0, 60, 100, 0, 720),
+				note(0, 62, 100, 720, 720),
+				note(0, 64, 100, 1680, 720),
+				note(0, 66, 127, 2400, 480),
+				note(0, 58, 127, 2880, 120),
+			},
+		},
+		{
+			"L8 [C:D]3 E // comment\n N72,2",
+			[]midi.Note{
+				note(0, 60, 100, 0, 240),
+				note(0, 62, 100, 240, 240),
+				note(0, 60, 100, 480, 240),
+				note(0, 62, 100, 720, 240),
+				note(0, 60, 100, 960, 240),
+				note(0, 64, 100, 1200, 240),
+				note(0, 72, 100, 1440, 960),
+			},
+		},
+		{
+			"[[c]2 d]2 c&e",
+			[]midi.Note{
+				note(0, 60, 100, 0, 480),
+				note(0, 60, 100, 480, 480),
+				note(0, 62, 100, 960, 480),
+				note(0, 60, 100, 1440, 480),
+				note(0, 60, 100, 1920, 480),
+				note(0, 62, 100, 2400, 480),
+				note(0, 60, 100, 2880, 480),
+				note(0, 64, 100, 3360, 480),
+			},
+		},
+		{
+			"c12 c12 c12 r2.",
+			[]midi.Note{
+				note(0, 60, 100, 0, 160),
+				note(0, 60, 100, 160, 160),
+				note(0, 60, 100, 320, 160),
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		f, err := Parse(strings.NewReader(testcase.source))
+		if err != nil {
+			t.Errorf("Parse(%q) = err: %v", testcase.source, err)
+			continue
+		}
+		if len(f.Tracks) != 2 {
+			t.Errorf("Parse(%q) has %d tracks want 2", testcase.source, len(f.Tracks))
+			continue
+		}
+		if got := f.Tracks[1].Notes(); !reflect.DeepEqual(got, testcase.want) {
+			t.Errorf("Parse(%q) = %v want %v", testcase.source, got, testcase.want)
+		}
+	}
+}
+
+func TestParseChannels(t *testing.T) {
+	f, err := Parse(strings.NewReader("t90 o5 c1 t150; @40 o3 c2 ;; d"))
+	if err != nil {
+		t.Fatalf("Parse() = err: %v", err)
+	}
+
+	if f.Header.Format != 1 || f.Header.NumberOfTracks != 4 || len(f.Tracks) != 4 {
+		t.Fatalf("Parse() header = %+v with %d tracks want format 1 with 4 tracks", f.Header, len(f.Tracks))
+	}
+
+	wantTempo := []midi.TimedEvent{
+		{Tick: 0, Event: midi.NewTempoEvent(666667)},
+		{Tick: 1920, Event: midi.NewTempoEvent(400000)},
+		{Tick: 1920, Event: midi.MetaEvent{Type: midi.EndOfTrack}},
+	}
+	if got := f.Tracks[0].TimedEvents(); !reflect.DeepEqual(got, wantTempo) {
+		t.Errorf("Parse() conductor = %v want %v", got, wantTempo)
+	}
+
+	wantNotes := [][]midi.Note{
+		{note(0, 72, 100, 0, 1920)},
+		{note(1, 48, 100, 0, 960)},
+		{note(2, 62, 100, 0, 480)},
+	}
+	for i, want := range wantNotes {
+		if got := f.Tracks[i+1].Notes(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse() track %d = %v want %v", i+1, got, want)
+		}
+	}
+
+	for i, end := range []int64{1920, 960, 480} {
+		timed := f.Tracks[i+1].TimedEvents()
+		want := midi.TimedEvent{Tick: end, Event: midi.MetaEvent{Type: midi.EndOfTrack}}
+		if got := timed[len(timed)-1]; !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse() track %d ends with %v want %v", i+1, got, want)
+		}
+	}
+
+	wantProgram := midi.MIDIEvent{Type: midi.ProgramChange, Channel: 1, ProgramNumber: 40}
+	if got := f.Tracks[2].Events[0]; !reflect.DeepEqual(got, wantProgram) {
+		t.Errorf("Parse() program = %v want %v", got, wantProgram)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, source := range []string{
+		"",
+		" ; ",
+		"c7",
+		"[cd",
+		"x",
+		"o12",
+		"^4",
+		"n200",
+		"l",
+		"cd:e",
+		"v16",
+		"t0",
+		"[[[c]1000]1000]1000",
+		"[[[]1048576]1048576]1048576",
+	} {
+		if f, err := Parse(strings.NewReader(source)); err == nil {
+			t.Errorf("Parse(%q) = %v want error", source, f)
+		}
+	}
+}
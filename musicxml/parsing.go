@@ -0,0 +1,332 @@
+package musicxml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/steinarvk/midi"
+)
+
+const DefaultVelocity = 80
+
+var steps = map[string]int{
+	"C": 0,
+	"D": 2,
+	"E": 4,
+	"F": 5,
+	"G": 7,
+	"A": 9,
+	"B": 11,
+}
+
+// Parse reads a partwise MusicXML score as a format 1 file. The first
+// track holds the title, tempo, time and key signatures; it is followed by
+// a track for each part, named after the part, which starts with a
+// program change if the part has a MIDI instrument. The file's division
+// is the least common multiple of the divisions used in the score, so
+// that every duration is a whole number of ticks. Tied notes are merged
+// into single notes; grace notes and unpitched notes are left out.
+func Parse(r io.Reader) (*midi.File, error) {
+	var doc ScorePartwise
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid MusicXML: %v", err)
+	}
+	return Unconvert(&doc)
+}
+
+// Unconvert builds a file from a parsed MusicXML document.
+func Unconvert(doc *ScorePartwise) (*midi.File, error) {
+	if len(doc.Parts) == 0 {
+		return nil, errors.New("score has no parts")
+	}
+
+	division := int64(1)
+	for _, part := range doc.Parts {
+		for _, m := range part.Measures {
+			for _, item := range m.Items {
+				if attrs, ok := item.(*Attributes); ok && attrs.Divisions > 0 {
+					division = lcm(division, attrs.Divisions)
+				}
+			}
+		}
+	}
+	if division > math.MaxInt16 {
+		return nil, fmt.Errorf("divisions need %d ticks per quarter note, more than a MIDI file allows", division)
+	}
+
+	conductor := midi.NewSimpleWriter(int16(division))
+	if doc.Work != nil && doc.Work.Title != "" {
+		conductor.Event(midi.MetaEvent{Type: midi.TrackName, Data: []byte(doc.Work.Title)})
+	}
+
+	rv := &midi.File{
+		Header: &midi.Header{
+			Format:         1,
+			NumberOfTracks: uint16(len(doc.Parts) + 1),
+			Division:       int16(division),
+		},
+		Tracks: []*midi.Track{nil},
+	}
+
+	names := map[string]ScorePart{}
+	for _, sp := range doc.PartList.ScoreParts {
+		names[sp.ID] = sp
+	}
+
+	tempos := map[int]bool{}
+	end := 0
+	for i, part := range doc.Parts {
+		p := &partReader{
+			division:   division,
+			writer:     midi.NewSimpleWriter(int16(division)),
+			conductor:  conductor,
+			signatures: i == 0,
+			tempos:     tempos,
+			channel:    defaultChannel(i),
+			tied:       map[int]*pendingNote{},
+		}
+
+		sp := names[part.ID]
+		if sp.Name != "" {
+			p.writer.Event(midi.MetaEvent{Type: midi.TrackName, Data: []byte(sp.Name)})
+		}
+		if len(sp.MIDIInstruments) > 0 {
+			inst := sp.MIDIInstruments[0]
+			if inst.Channel > 0 {
+				p.channel = (inst.Channel - 1) % 16
+			}
+			if inst.Program > 0 {
+				p.writer.Event(midi.MIDIEvent{
+					Type:          midi.ProgramChange,
+					Channel:       p.channel,
+					ProgramNumber: inst.Program - 1,
+				})
+			}
+		}
+
+		if err := p.read(part); err != nil {
+			return nil, fmt.Errorf("part %q: %v", part.ID, err)
+		}
+		if p.end > end {
+			end = p.end
+		}
+		rv.Tracks = append(rv.Tracks, p.writer.File().Tracks[0])
+	}
+
+	conductor.EventAt(end, midi.MetaEvent{Type: midi.EndOfTrack})
+	rv.Tracks[0] = conductor.File().Tracks[0]
+	return rv, nil
+}
+
+// defaultChannel numbers parts without a MIDI instrument, skipping the
+// percussion channel.
+func defaultChannel(part int) int {
+	if part >= 9 {
+		part++
+	}
+	return part % 16
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func lcm(a, b int64) int64 {
+	return a / gcd(a, b) * b
+}
+
+type pendingNote struct {
+	start, end int
+}
+
+// partReader reads the measures of one part, keeping track of time in
+// ticks. Notes waiting for the continuation of a tie are kept by key.
+type partReader struct {
+	division  int64
+	writer    *midi.SimpleWriter
+	conductor *midi.SimpleWriter
+
+	// signatures is set for the first part, which supplies the time and
+	// key signatures; tempos already written are shared by all parts.
+	signatures bool
+	tempos     map[int]bool
+
+	channel   int
+	scale     int64
+	now       int
+	lastStart int
+	tied      map[int]*pendingNote
+
+	// end is the last tick of the part, where its track ends.
+	end int
+}
+
+func (p *partReader) read(part Part) error {
+	var measureStart int
+	for _, m := range part.Measures {
+		p.now = measureStart
+		measureEnd := measureStart
+
+		for _, item := range m.Items {
+			switch x := item.(type) {
+			case *Attributes:
+				if err := p.attributes(x); err != nil {
+					return fmt.Errorf("measure %s: %v", m.Number, err)
+				}
+			case *Direction:
+				if x.Sound != nil {
+					p.sound(x.Sound)
+				}
+			case *Sound:
+				p.sound(x)
+			case *Note:
+				if err := p.note(x); err != nil {
+					return fmt.Errorf("measure %s: %v", m.Number, err)
+				}
+			case *Backup:
+				p.now -= p.ticks(x.Duration)
+			case *Forward:
+				p.now += p.ticks(x.Duration)
+			}
+			if p.now > measureEnd {
+				measureEnd = p.now
+			}
+		}
+
+		measureStart = measureEnd
+	}
+	if measureStart > p.end {
+		p.end = measureStart
+	}
+
+	var keys []int
+	for key := range p.tied {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	for _, key := range keys {
+		p.noteAt(p.tied[key], key)
+	}
+	p.writer.EventAt(p.end, midi.MetaEvent{Type: midi.EndOfTrack})
+	return nil
+}
+
+func (p *partReader) ticks(duration int64) int {
+	return int(duration * p.scale)
+}
+
+func (p *partReader) attributes(attrs *Attributes) error {
+	if attrs.Divisions > 0 {
+		p.scale = p.division / attrs.Divisions
+	}
+	if !p.signatures {
+		return nil
+	}
+
+	if attrs.Time != nil {
+		numerator := 0
+		for _, beats := range strings.Split(attrs.Time.Beats, "+") {
+			n, err := strconv.Atoi(strings.TrimSpace(beats))
+			if err != nil {
+				return fmt.Errorf("invalid time signature beats %q", attrs.Time.Beats)
+			}
+			numerator += n
+		}
+		p.conductor.EventAt(p.now, midi.NewTimeSignatureEvent(numerator, attrs.Time.BeatType))
+	}
+	if attrs.Key != nil {
+		p.conductor.EventAt(p.now, midi.NewKeySignatureEvent(attrs.Key.Fifths, attrs.Key.Mode == "minor"))
+	}
+	return nil
+}
+
+func (p *partReader) sound(s *Sound) {
+	if s.Tempo <= 0 || p.tempos[p.now] {
+		return
+	}
+	p.tempos[p.now] = true
+	p.conductor.EventAt(p.now, midi.NewTempoEvent(int64(math.Floor(60e6/s.Tempo+0.5))))
+}
+
+func (p *partReader) note(n *Note) error {
+	if n.Grace != nil {
+		return nil
+	}
+	if p.scale == 0 {
+		return errors.New("note before divisions are given")
+	}
+
+	start := p.now
+	if n.Chord != nil {
+		start = p.lastStart
+	} else {
+		p.now += p.ticks(n.Duration)
+	}
+	p.lastStart = start
+	end := start + p.ticks(n.Duration)
+
+	if n.Pitch == nil {
+		return nil
+	}
+	natural, ok := steps[n.Pitch.Step]
+	if !ok {
+		return fmt.Errorf("invalid step %q", n.Pitch.Step)
+	}
+	key := (n.Pitch.Octave+1)*12 + natural + int(math.Floor(n.Pitch.Alter+0.5))
+	if key < 0 || key > 127 {
+		return fmt.Errorf("pitch %s%d is out of range", n.Pitch.Step, n.Pitch.Octave)
+	}
+
+	pending, continued := p.tied[key]
+	if continued && n.hasTie("stop") && pending.end == start {
+		pending.end = end
+	} else {
+		if continued {
+			p.noteAt(pending, key)
+		}
+		pending = &pendingNote{start: start, end: end}
+	}
+	delete(p.tied, key)
+
+	if n.hasTie("start") {
+		p.tied[key] = pending
+	} else {
+		p.noteAt(pending, key)
+	}
+	return nil
+}
+
+func (p *partReader) noteAt(n *pendingNote, key int) {
+	p.writer.EventAt(n.start, midi.MIDIEvent{Type: midi.NoteOn, Channel: p.channel, Key: key, Velocity: DefaultVelocity})
+	p.writer.EventAt(n.end, midi.MIDIEvent{Type: midi.NoteOff, Channel: p.channel, Key: key, Velocity: DefaultVelocity})
+	if n.end > p.end {
+		p.end = n.end
+	}
+}
+
+// hasTie looks for a tie of the given type among both the sounding ties
+// and the notated ones, as some scores only have one kind.
+func (n *Note) hasTie(tieType string) bool {
+	for _, tie := range n.Ties {
+		if tie.Type == tieType {
+			return true
+		}
+	}
+	if n.Notations != nil {
+		for _, tied := range n.Notations.Tied {
+			if tied.Type == tieType {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package musicxml
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/steinarvk/midi"
+)
+
+func TestParseRoundtrip(t *testing.T) {
+	f := testFile()
+
+	buf := bytes.NewBuffer(nil)
+	if err := Write(buf, f); err != nil {
+		t.Fatalf("Write() = err: %v", err)
+	}
+
+	got, err := Parse(buf)
+	if err != nil {
+		t.Fatalf("Parse() = err: %v", err)
+	}
+
+	if got.Header.Format != 1 || got.Header.Division != 480 || len(got.Tracks) != 3 {
+		t.Fatalf("Parse() header = %+v with %d tracks want format 1, division 480, 3 tracks", got.Header, len(got.Tracks))
+	}
+
+	for i := 1; i < 3; i++ {
+		if !reflect.DeepEqual(got.Tracks[i].Notes(), f.Tracks[i].Notes()) {
+			t.Errorf("Parse() track %d notes = %v want %v", i, got.Tracks[i].Notes(), f.Tracks[i].Notes())
+		}
+	}
+
+	wantConductor := []midi.Event{
+		midi.MetaEvent{Type: midi.TrackName, Data: []byte("Example")},
+		midi.NewTimeSignatureEvent(3, 4),
+		midi.NewKeySignatureEvent(1, false),
+		midi.NewTempoEvent(500000),
+		midi.TimeDeltaEvent(2880),
+		midi.MetaEvent{Type: midi.EndOfTrack},
+	}
+	if !reflect.DeepEqual(got.Tracks[0].Events, wantConductor) {
+		t.Errorf("Parse() conductor = %v want %v", got.Tracks[0].Events, wantConductor)
+	}
+
+	wantMelody := []midi.Event{
+		midi.MetaEvent{Type: midi.TrackName, Data: []byte("Melody")},
+		midi.MIDIEvent{Type: midi.ProgramChange, ProgramNumber: 40},
+	}
+	if !reflect.DeepEqual(got.Tracks[1].Events[:2], wantMelody) {
+		t.Errorf("Parse() melody = %v want it to start with %v", got.Tracks[1].Events[:2], wantMelody)
+	}
+}
+
+func TestParse(t *testing.T) {
+	const doc = `<?xml version="1.0" encoding="UTF-8"?>
+<score-partwise version="3.1">
+  <part-list>
+    <score-part id="P1"><part-name>Piano</part-name></score-part>
+    <score-part id="P2">
+      <part-name>Flute</part-name>
+      <midi-instrument id="P2-I1"><midi-channel>3</midi-channel><midi-program>74</midi-program></midi-instrument>
+    </score-part>
+  </part-list>
+  <part id="P1">
+    <measure number="1">
+      <attributes>
+        <divisions>2</divisions>
+        <key><fifths>-1</fifths><mode>minor</mode></key>
+        <time><beats>2+1</beats><beat-type>4</beat-type></time>
+      </attributes>
+      <sound tempo="90"/>
+      <note><grace/><pitch><step>B</step><octave>4</octave></pitch><type>eighth</type></note>
+      <note><pitch><step>A</step><octave>4</octave></pitch><duration>4</duration></note>
+      <note><chord/><pitch><step>C</step><alter>1</alter><octave>5</octave></pitch><duration>4</duration></note>
+      <note><rest/><duration>1</duration></note>
+      <note><pitch><step>D</step><octave>5</octave></pitch><duration>1</duration><notations><tied type="start"/></notations></note>
+      <backup><duration>6</duration></backup>
+      <forward><duration>2</duration></forward>
+      <note><pitch><step>D</step><octave>3</octave></pitch><duration>4</duration></note>
+    </measure>
+    <measure number="2">
+      <note><pitch><step>D</step><octave>5</octave></pitch><duration>2</duration><notations><tied type="stop"/></notations></note>
+    </measure>
+  </part>
+  <part id="P2">
+    <measure number="1">
+      <attributes><divisions>3</divisions></attributes>
+      <note><pitch><step>E</step><alter>-1</alter><octave>5</octave></pitch><duration>9</duration></note>
+    </measure>
+    <measure number="2">
+      <direction><sound tempo="120"/></direction>
+      <note><pitch><step>F</step><octave>5</octave></pitch><duration>1</duration></note>
+    </measure>
+  </part>
+</score-partwise>
+`
+
+	f, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Parse() = err: %v", err)
+	}
+
+	if f.Header.Division != 6 {
+		t.Errorf("Parse() division = %d want 6", f.Header.Division)
+	}
+
+	wantConductor := []midi.TimedEvent{
+		{Tick: 0, Event: midi.NewTimeSignatureEvent(3, 4)},
+		{Tick: 0, Event: midi.NewKeySignatureEvent(-1, true)},
+		{Tick: 0, Event: midi.NewTempoEvent(666667)},
+		{Tick: 18, Event: midi.NewTempoEvent(500000)},
+		{Tick: 24, Event: midi.MetaEvent{Type: midi.EndOfTrack}},
+	}
+	if got := f.Tracks[0].TimedEvents(); !reflect.DeepEqual(got, wantConductor) {
+		t.Errorf("Parse() conductor = %v want %v", got, wantConductor)
+	}
+
+	wantPiano := []midi.Note{
+		{Channel: 0, Key: 69, Velocity: DefaultVelocity, Start: 0, Duration: 12},
+		{Channel: 0, Key: 73, Velocity: DefaultVelocity, Start: 0, Duration: 12},
+		{Channel: 0, Key: 50, Velocity: DefaultVelocity, Start: 6, Duration: 12},
+		{Channel: 0, Key: 74, Velocity: DefaultVelocity, Start: 15, Duration: 9},
+	}
+	if got := f.Tracks[1].Notes(); !reflect.DeepEqual(got, wantPiano) {
+		t.Errorf("Parse() piano = %v want %v", got, wantPiano)
+	}
+
+	wantFlute := []midi.Note{
+		{Channel: 2, Key: 75, Velocity: DefaultVelocity, Start: 0, Duration: 18},
+		{Channel: 2, Key: 77, Velocity: DefaultVelocity, Start: 18, Duration: 2},
+	}
+	if got := f.Tracks[2].Notes(); !reflect.DeepEqual(got, wantFlute) {
+		t.Errorf("Parse() flute = %v want %v", got, wantFlute)
+	}
+	for i, end := range []int64{24, 20} {
+		timed := f.Tracks[i+1].TimedEvents()
+		want := midi.TimedEvent{Tick: end, Event: midi.MetaEvent{Type: midi.EndOfTrack}}
+		if got := timed[len(timed)-1]; !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse() track %d ends with %v want %v", i+1, got, want)
+		}
+	}
+	if got, want := f.Tracks[2].Events[1], (midi.MIDIEvent{Type: midi.ProgramChange, Channel: 2, ProgramNumber: 73}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() flute program = %v want %v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, doc := range []string{
+		`<score-partwise><part-list/></score-partwise>`,
+		`<score-partwise><part id="P1"><measure number="1"><note><pitch><step>C</step><octave>4</octave></pitch><duration>1</duration></note></measure></part></score-partwise>`,
+		`<score-partwise><part id="P1"><measure number="1"><attributes><divisions>1</divisions></attributes><note><pitch><step>H</step><octave>4</octave></pitch><duration>1</duration></note></measure></part></score-partwise>`,
+		`<score-partwise><part id="P1"><measure number="1">`,
+	} {
+		if f, err := Parse(strings.NewReader(doc)); err == nil {
+			t.Errorf("Parse(%q) = %v want error", doc, f)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Package musicxml converts between MIDI files and MusicXML scores in the
+// partwise layout, with one part for each track that has notes.
+package musicxml
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+const (
+	version = "3.1"
+	doctype = `<!DOCTYPE score-partwise PUBLIC "-//Recordare//DTD MusicXML 3.1 Partwise//EN" "http://www.musicxml.org/dtds/partwise.dtd">`
+)
+
+// The types below cover the subset of MusicXML read and written by this
+// package. Field order follows the order the schema requires.
+
+type ScorePartwise struct {
+	XMLName  xml.Name `xml:"score-partwise"`
+	Version  string   `xml:"version,attr,omitempty"`
+	Work     *Work    `xml:"work"`
+	PartList PartList `xml:"part-list"`
+	Parts    []Part   `xml:"part"`
+}
+
+type Work struct {
+	Title string `xml:"work-title"`
+}
+
+type PartList struct {
+	ScoreParts []ScorePart `xml:"score-part"`
+}
+
+type ScorePart struct {
+	ID               string            `xml:"id,attr"`
+	Name             string            `xml:"part-name"`
+	ScoreInstruments []ScoreInstrument `xml:"score-instrument"`
+	MIDIInstruments  []MIDIInstrument  `xml:"midi-instrument"`
+}
+
+type ScoreInstrument struct {
+	ID   string `xml:"id,attr"`
+	Name string `xml:"instrument-name"`
+}
+
+// MIDIInstrument numbers channels and programs from 1.
+type MIDIInstrument struct {
+	ID      string `xml:"id,attr"`
+	Channel int    `xml:"midi-channel,omitempty"`
+	Program int    `xml:"midi-program,omitempty"`
+}
+
+type Part struct {
+	ID       string    `xml:"id,attr"`
+	Measures []Measure `xml:"measure"`
+}
+
+// Measure holds its contents in order; each item is an *Attributes,
+// *Direction, *Sound, *Note, *Backup or *Forward.
+type Measure struct {
+	Number string        `xml:"number,attr"`
+	Items  []interface{} `xml:""`
+}
+
+// UnmarshalXML decodes the contents of a measure in order, skipping
+// elements of other types.
+func (m *Measure) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "number" {
+			m.Number = attr.Value
+		}
+	}
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.EndElement:
+			return nil
+		case xml.StartElement:
+			var item interface{}
+			switch t.Name.Local {
+			case "attributes":
+				item = &Attributes{}
+			case "direction":
+				item = &Direction{}
+			case "sound":
+				item = &Sound{}
+			case "note":
+				item = &Note{}
+			case "backup":
+				item = &Backup{}
+			case "forward":
+				item = &Forward{}
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.DecodeElement(item, &t); err != nil {
+				return err
+			}
+			m.Items = append(m.Items, item)
+		}
+	}
+}
+
+type Attributes struct {
+	XMLName   xml.Name `xml:"attributes"`
+	Divisions int64    `xml:"divisions,omitempty"`
+	Key       *Key     `xml:"key"`
+	Time      *Time    `xml:"time"`
+	Clef      *Clef    `xml:"clef"`
+}
+
+type Key struct {
+	Fifths int    `xml:"fifths"`
+	Mode   string `xml:"mode,omitempty"`
+}
+
+// Time has Beats such as "3", or "3+2" for a compound meter.
+type Time struct {
+	Beats    string `xml:"beats"`
+	BeatType int    `xml:"beat-type"`
+}
+
+type Clef struct {
+	Sign string `xml:"sign"`
+	Line int    `xml:"line"`
+}
+
+type Direction struct {
+	XMLName       xml.Name       `xml:"direction"`
+	Placement     string         `xml:"placement,attr,omitempty"`
+	DirectionType *DirectionType `xml:"direction-type"`
+	Sound         *Sound         `xml:"sound"`
+}
+
+type DirectionType struct {
+	Metronome *Metronome `xml:"metronome"`
+}
+
+type Metronome struct {
+	BeatUnit  string `xml:"beat-unit"`
+	PerMinute int    `xml:"per-minute"`
+}
+
+// Sound gives the tempo in quarter notes per minute.
+type Sound struct {
+	XMLName xml.Name `xml:"sound"`
+	Tempo   float64  `xml:"tempo,attr,omitempty"`
+}
+
+type Note struct {
+	XMLName    xml.Name   `xml:"note"`
+	Grace      *Empty     `xml:"grace"`
+	Chord      *Empty     `xml:"chord"`
+	Pitch      *Pitch     `xml:"pitch"`
+	Rest       *Empty     `xml:"rest"`
+	Duration   int64      `xml:"duration"`
+	Ties       []Tie      `xml:"tie"`
+	Voice      string     `xml:"voice,omitempty"`
+	Type       string     `xml:"type,omitempty"`
+	Dots       []Empty    `xml:"dot"`
+	Accidental string     `xml:"accidental,omitempty"`
+	Notations  *Notations `xml:"notations"`
+}
+
+type Empty struct{}
+
+// Pitch has Alter in semitones, which may be fractional for microtones.
+type Pitch struct {
+	Step   string  `xml:"step"`
+	Alter  float64 `xml:"alter,omitempty"`
+	Octave int     `xml:"octave"`
+}
+
+// Tie and Tied have Type "start" or "stop".
+type Tie struct {
+	Type string `xml:"type,attr"`
+}
+
+type Notations struct {
+	Tied []Tied `xml:"tied"`
+}
+
+type Tied struct {
+	Type string `xml:"type,attr"`
+}
+
+type Backup struct {
+	XMLName  xml.Name `xml:"backup"`
+	Duration int64    `xml:"duration"`
+}
+
+type Forward struct {
+	XMLName  xml.Name `xml:"forward"`
+	Duration int64    `xml:"duration"`
+}
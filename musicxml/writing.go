@@ -0,0 +1,262 @@
+package musicxml
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/steinarvk/midi"
+	"github.com/steinarvk/midi/score"
+)
+
+var typeNames = map[int]string{
+	1:  "whole",
+	2:  "half",
+	4:  "quarter",
+	8:  "eighth",
+	16: "16th",
+	32: "32nd",
+	64: "64th",
+}
+
+var accidentalNames = map[int]string{
+	-2: "flat-flat",
+	-1: "flat",
+	0:  "natural",
+	1:  "sharp",
+	2:  "double-sharp",
+}
+
+// Write writes a file as a partwise MusicXML score, with one part for
+// each track that has notes, named after the track's TrackName. Tracks
+// should be quantized, with every note and rest a whole number of 64th
+// notes long. Measures follow the time signatures, notes are spelled
+// according to the key signatures, and notes that do not fit a single
+// note value are split and tied.
+func Write(w io.Writer, f *midi.File) error {
+	doc, err := Convert(f)
+	if err != nil {
+		return err
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+	bw.WriteString(doctype + "\n")
+	bw.Write(data)
+	bw.WriteString("\n")
+	return bw.Flush()
+}
+
+// Convert builds the MusicXML document that Write writes.
+func Convert(f *midi.File) (*ScorePartwise, error) {
+	var trackNos []int
+	for i, track := range f.Tracks {
+		if len(track.Notes()) > 0 {
+			trackNos = append(trackNos, i)
+		}
+	}
+	if len(trackNos) == 0 {
+		return nil, errors.New("no notes to write")
+	}
+
+	scores, err := score.LayoutTracks(f, trackNos)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &ScorePartwise{Version: version}
+	if len(f.Tracks) > 1 && trackNos[0] != 0 {
+		if name := trackName(f.Tracks[0]); name != "" {
+			doc.Work = &Work{Title: name}
+		}
+	}
+
+	for i, trackNo := range trackNos {
+		track := f.Tracks[trackNo]
+		id := fmt.Sprintf("P%d", i+1)
+
+		name := trackName(track)
+		if name == "" {
+			name = fmt.Sprintf("Track %d", trackNo+1)
+		}
+		doc.PartList.ScoreParts = append(doc.PartList.ScoreParts, ScorePart{
+			ID:               id,
+			Name:             name,
+			ScoreInstruments: []ScoreInstrument{{ID: id + "-I1", Name: name}},
+			MIDIInstruments:  []MIDIInstrument{instrument(id+"-I1", track)},
+		})
+
+		part, err := convertPart(scores[i], clef(track), i == 0)
+		if err != nil {
+			return nil, fmt.Errorf("track %d: %v", trackNo, err)
+		}
+		part.ID = id
+		doc.Parts = append(doc.Parts, part)
+	}
+
+	return doc, nil
+}
+
+func trackName(track *midi.Track) string {
+	for _, evt := range track.Events {
+		if meta, ok := evt.(midi.MetaEvent); ok && meta.Type == midi.TrackName {
+			return string(meta.Data)
+		}
+	}
+	return ""
+}
+
+// instrument takes the channel of the track's first note and the first
+// program selected on it.
+func instrument(id string, track *midi.Track) MIDIInstrument {
+	channel := track.Notes()[0].Channel
+	rv := MIDIInstrument{ID: id, Channel: channel + 1}
+	for _, evt := range track.Events {
+		if m, ok := evt.(midi.MIDIEvent); ok && m.Type == midi.ProgramChange && m.Channel == channel {
+			rv.Program = m.ProgramNumber + 1
+			break
+		}
+	}
+	return rv
+}
+
+// clef picks the bass clef for tracks whose notes are mostly below
+// middle C.
+func clef(track *midi.Track) *Clef {
+	notes := track.Notes()
+	var sum int
+	for _, note := range notes {
+		sum += note.Key
+	}
+	if sum < 57*len(notes) {
+		return &Clef{Sign: "F", Line: 4}
+	}
+	return &Clef{Sign: "G", Line: 2}
+}
+
+func convertPart(s *score.Score, c *Clef, withTempo bool) (Part, error) {
+	var rv Part
+
+	for i, bar := range s.Bars {
+		m := Measure{Number: strconv.Itoa(i + 1)}
+
+		if i == 0 || bar.MeterChanged || bar.KeyChanged {
+			attrs := &Attributes{}
+			if i == 0 {
+				attrs.Divisions = s.Division
+				attrs.Clef = c
+			}
+			if i == 0 || bar.KeyChanged {
+				attrs.Key = &Key{Fifths: bar.Key.Fifths, Mode: "major"}
+				if bar.Key.Minor {
+					attrs.Key.Mode = "minor"
+				}
+			}
+			if i == 0 || bar.MeterChanged {
+				attrs.Time = &Time{Beats: strconv.Itoa(bar.Meter.Numerator), BeatType: bar.Meter.Denominator}
+			}
+			m.Items = append(m.Items, attrs)
+		}
+
+		if i == 0 && withTempo && s.Tempo > 0 {
+			bpm := 60e6 / float64(s.Tempo)
+			m.Items = append(m.Items, &Direction{
+				Placement: "above",
+				DirectionType: &DirectionType{
+					Metronome: &Metronome{BeatUnit: "quarter", PerMinute: int(math.Floor(bpm + 0.5))},
+				},
+				Sound: &Sound{Tempo: math.Floor(bpm*100+0.5) / 100},
+			})
+		}
+
+		accidentals := map[string]int{}
+		for _, item := range bar.Items {
+			values, err := s.Values(item.Duration)
+			if err != nil {
+				return Part{}, fmt.Errorf("%s at tick %d is not quantized", describe(item), item.Start)
+			}
+			for j, value := range values {
+				tiedFromPrevious := j > 0 || item.TiedFromPrevious
+				tiedToNext := j < len(values)-1 || item.TiedToNext
+				for _, note := range convertItem(item, value, bar.Key, accidentals, tiedFromPrevious, tiedToNext) {
+					m.Items = append(m.Items, note)
+				}
+			}
+		}
+
+		rv.Measures = append(rv.Measures, m)
+	}
+
+	return rv, nil
+}
+
+func describe(item score.Item) string {
+	if item.IsRest() {
+		return "rest"
+	}
+	return fmt.Sprintf("note %v", item.Keys)
+}
+
+// convertItem writes a rest, a note or a chord of a single note value.
+// Accidentals are shown where the key signature and earlier accidentals
+// in the measure do not imply them, except on notes tied over from before.
+func convertItem(item score.Item, value score.Value, k score.Key, accidentals map[string]int, tiedFromPrevious, tiedToNext bool) []*Note {
+	base := Note{
+		Duration: value.Ticks,
+		Voice:    "1",
+		Type:     typeNames[value.Denominator],
+		Dots:     make([]Empty, value.Dots),
+	}
+
+	if item.IsRest() {
+		base.Rest = &Empty{}
+		return []*Note{&base}
+	}
+
+	var rv []*Note
+	for i, key := range item.Keys {
+		note := base
+		if i > 0 {
+			note.Chord = &Empty{}
+		}
+
+		letter, alter, octave := k.Spell(key)
+		note.Pitch = &Pitch{Step: string(letter), Alter: float64(alter), Octave: octave}
+
+		if !tiedFromPrevious {
+			name := fmt.Sprintf("%c%d", letter, octave)
+			implied, ok := accidentals[name]
+			if !ok {
+				implied = k.Accidental(letter)
+			}
+			if alter != implied {
+				note.Accidental = accidentalNames[alter]
+				accidentals[name] = alter
+			}
+		}
+
+		if tiedFromPrevious || tiedToNext {
+			note.Notations = &Notations{}
+		}
+		if tiedFromPrevious {
+			note.Ties = append(note.Ties, Tie{"stop"})
+			note.Notations.Tied = append(note.Notations.Tied, Tied{"stop"})
+		}
+		if tiedToNext {
+			note.Ties = append(note.Ties, Tie{"start"})
+			note.Notations.Tied = append(note.Notations.Tied, Tied{"start"})
+		}
+
+		rv = append(rv, &note)
+	}
+	return rv
+}
@@ -0,0 +1,166 @@
+package musicxml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/steinarvk/midi"
+)
+
+func summarize(m Measure) []string {
+	var rv []string
+	for _, item := range m.Items {
+		note, ok := item.(*Note)
+		if !ok {
+			continue
+		}
+		s := "rest"
+		if note.Pitch != nil {
+			s = fmt.Sprintf("%s%d", note.Pitch.Step, note.Pitch.Octave)
+			if note.Pitch.Alter != 0 {
+				s = fmt.Sprintf("%s%+g", s, note.Pitch.Alter)
+			}
+		}
+		if note.Chord != nil {
+			s = "+" + s
+		}
+		s += " " + note.Type + strings.Repeat(".", len(note.Dots))
+		if note.Accidental != "" {
+			s += " " + note.Accidental
+		}
+		for _, tie := range note.Ties {
+			s += " tie-" + tie.Type
+		}
+		rv = append(rv, s)
+	}
+	return rv
+}
+
+func testFile() *midi.File {
+	conductor := midi.NewSimpleWriter(480)
+	conductor.Event(midi.MetaEvent{Type: midi.TrackName, Data: []byte("Example")})
+	conductor.Event(midi.NewTimeSignatureEvent(3, 4))
+	conductor.Event(midi.NewKeySignatureEvent(1, false))
+	conductor.Event(midi.NewTempoEvent(500000))
+
+	melody := midi.NewSimpleWriter(480)
+	melody.Event(midi.MetaEvent{Type: midi.TrackName, Data: []byte("Melody")})
+	melody.Event(midi.MIDIEvent{Type: midi.ProgramChange, ProgramNumber: 40})
+	melody.Play([]int{65}, 80, 480)
+	melody.Play([]int{72}, 80, 1200)
+	melody.Play([]int{55, 59, 66}, 80, 480)
+
+	bass := midi.NewSimpleWriter(480)
+	bass.Play([]int{43}, 80, 1440)
+
+	return &midi.File{
+		Header: &midi.Header{Format: 1, NumberOfTracks: 3, Division: 480},
+		Tracks: []*midi.Track{
+			conductor.File().Tracks[0],
+			melody.File().Tracks[0],
+			bass.File().Tracks[0],
+		},
+	}
+}
+
+func TestConvert(t *testing.T) {
+	doc, err := Convert(testFile())
+	if err != nil {
+		t.Fatalf("Convert() = err: %v", err)
+	}
+
+	if doc.Work == nil || doc.Work.Title != "Example" {
+		t.Errorf("Convert().Work = %v want title Example", doc.Work)
+	}
+
+	wantParts := []ScorePart{
+		{
+			ID:               "P1",
+			Name:             "Melody",
+			ScoreInstruments: []ScoreInstrument{{ID: "P1-I1", Name: "Melody"}},
+			MIDIInstruments:  []MIDIInstrument{{ID: "P1-I1", Channel: 1, Program: 41}},
+		},
+		{
+			ID:               "P2",
+			Name:             "Track 3",
+			ScoreInstruments: []ScoreInstrument{{ID: "P2-I1", Name: "Track 3"}},
+			MIDIInstruments:  []MIDIInstrument{{ID: "P2-I1", Channel: 1}},
+		},
+	}
+	if !reflect.DeepEqual(doc.PartList.ScoreParts, wantParts) {
+		t.Errorf("Convert().PartList = %v want %v", doc.PartList.ScoreParts, wantParts)
+	}
+
+	want := [][][]string{
+		{
+			{"F4 quarter natural", "C5 half tie-start"},
+			{"C5 eighth tie-stop", "G3 quarter", "+B3 quarter", "+F4+1 quarter", "rest quarter."},
+		},
+		{
+			{"G2 half."},
+			{"rest half."},
+		},
+	}
+
+	if len(doc.Parts) != len(want) {
+		t.Fatalf("Convert() has %d parts want %d", len(doc.Parts), len(want))
+	}
+	for i, part := range doc.Parts {
+		var got [][]string
+		for _, m := range part.Measures {
+			got = append(got, summarize(m))
+		}
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("Convert() part %d = %q want %q", i, got, want[i])
+		}
+	}
+
+	attrs, ok := doc.Parts[0].Measures[0].Items[0].(*Attributes)
+	if !ok {
+		t.Fatalf("Convert() first item = %v want attributes", doc.Parts[0].Measures[0].Items[0])
+	}
+	wantAttrs := &Attributes{
+		Divisions: 480,
+		Key:       &Key{Fifths: 1, Mode: "major"},
+		Time:      &Time{Beats: "3", BeatType: 4},
+		Clef:      &Clef{Sign: "G", Line: 2},
+	}
+	if !reflect.DeepEqual(attrs, wantAttrs) {
+		t.Errorf("Convert() attributes = %v want %v", attrs, wantAttrs)
+	}
+	if got := doc.Parts[1].Measures[0].Items[0].(*Attributes).Clef.Sign; got != "F" {
+		t.Errorf("Convert() bass clef = %q want F", got)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	if err := Write(buf, testFile()); err != nil {
+		t.Fatalf("Write() = err: %v", err)
+	}
+
+	got := buf.String()
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<!DOCTYPE score-partwise`,
+		`<score-partwise version="3.1">`,
+		`<part-name>Melody</part-name>`,
+		`<measure number="2">`,
+		`<sound tempo="120"></sound>`,
+		`<tie type="start"></tie>`,
+		`<tied type="start"></tied>`,
+		`<chord></chord>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Write() = %s want it to contain %s", got, want)
+		}
+	}
+
+	if err := xml.Unmarshal(buf.Bytes(), new(struct{})); err != nil {
+		t.Errorf("Write() wrote invalid XML: %v", err)
+	}
+}
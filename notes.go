@@ -0,0 +1,124 @@
+package midi
+
+import "sort"
+
+// Note is a sounding note, from its NoteOn to the matching NoteOff, with
+// times in ticks from the start of the track.
+type Note struct {
+	Channel  int
+	Key      int
+	Velocity int
+	Start    int64
+	Duration int64
+}
+
+func (n Note) End() int64 {
+	return n.Start + n.Duration
+}
+
+// TimedEvent is an event at an absolute time in ticks.
+type TimedEvent struct {
+	Tick  int64
+	Event Event
+}
+
+// TimedEvents returns the track's events, other than time deltas, with
+// their absolute times.
+func (t *Track) TimedEvents() []TimedEvent {
+	var rv []TimedEvent
+	var now int64
+	for _, evt := range t.Events {
+		if td, ok := evt.(TimeDeltaEvent); ok {
+			now += int64(td)
+			continue
+		}
+		rv = append(rv, TimedEvent{Tick: now, Event: evt})
+	}
+	return rv
+}
+
+// Length returns the time of the track's last event in ticks.
+func (t *Track) Length() int64 {
+	var now, last int64
+	for _, evt := range t.Events {
+		if td, ok := evt.(TimeDeltaEvent); ok {
+			now += int64(td)
+			continue
+		}
+		last = now
+	}
+	return last
+}
+
+// Notes pairs up the track's NoteOn and NoteOff events into notes, ordered
+// by start time and then key. When a key is struck again before it is
+// released, the earliest NoteOn is matched first. Notes still sounding at
+// the end of the track end at its last event.
+func (t *Track) Notes() []Note {
+	type channelKey struct {
+		channel, key int
+	}
+
+	var rv []Note
+	sounding := map[channelKey][]int{}
+	var now int64
+
+	for _, evt := range t.Events {
+		switch v := evt.(type) {
+		case TimeDeltaEvent:
+			now += int64(v)
+
+		case MIDIEvent:
+			ck := channelKey{v.Channel, v.Key}
+			switch v.Type {
+			case NoteOn:
+				sounding[ck] = append(sounding[ck], len(rv))
+				rv = append(rv, Note{
+					Channel:  v.Channel,
+					Key:      v.Key,
+					Velocity: v.Velocity,
+					Start:    now,
+				})
+
+			case NoteOff:
+				if started := sounding[ck]; len(started) > 0 {
+					rv[started[0]].Duration = now - rv[started[0]].Start
+					sounding[ck] = started[1:]
+				}
+			}
+		}
+	}
+
+	for _, started := range sounding {
+		for _, i := range started {
+			rv[i].Duration = now - rv[i].Start
+		}
+	}
+
+	sort.SliceStable(rv, func(i, j int) bool {
+		if rv[i].Start != rv[j].Start {
+			return rv[i].Start < rv[j].Start
+		}
+		return rv[i].Key < rv[j].Key
+	})
+
+	return rv
+}
+
+// Notes returns the notes of every track, ordered by start time and then
+// key.
+func (f *File) Notes() []Note {
+	var rv []Note
+	for _, track := range f.Tracks {
+		rv = append(rv, track.Notes()...)
+	}
+
+	sort.SliceStable(rv, func(i, j int) bool {
+		if rv[i].Start != rv[j].Start {
+			return rv[i].Start < rv[j].Start
+		}
+		return rv[i].Key < rv[j].Key
+	})
+
+	return rv
+}
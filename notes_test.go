@@ -0,0 +1,55 @@
+package midi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNotes(t *testing.T) {
+	sw := NewSimpleWriter(96)
+	sw.NoteAt(0, 48, 0x30, 20)
+	sw.Play([]int{72, 76}, 0x40, 10)
+	sw.Play([]int{74}, 0x50, 10)
+	sw.Event(MIDIEvent{Type: NoteOn, Channel: 1, Key: 60, Velocity: 0x60})
+	sw.TimeDelta(5)
+	sw.Event(MetaEvent{Type: EndOfTrack})
+
+	want := []Note{
+		{Channel: 0, Key: 48, Velocity: 0x30, Start: 0, Duration: 20},
+		{Channel: 0, Key: 72, Velocity: 0x40, Start: 0, Duration: 10},
+		{Channel: 0, Key: 76, Velocity: 0x40, Start: 0, Duration: 10},
+		{Channel: 0, Key: 74, Velocity: 0x50, Start: 10, Duration: 10},
+		{Channel: 1, Key: 60, Velocity: 0x60, Start: 20, Duration: 5},
+	}
+
+	trk := sw.File().Tracks[0]
+	if got := trk.Notes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Notes() = %v want %v", got, want)
+	}
+
+	if got := trk.Length(); got != 25 {
+		t.Errorf("Length() = %d want %d", got, 25)
+	}
+}
+
+func TestFileNotes(t *testing.T) {
+	a := NewSimpleWriter(96)
+	a.NoteAt(10, 60, 0x40, 10)
+	a.NoteAt(0, 64, 0x40, 10)
+	b := NewSimpleWriter(96)
+	b.NoteAt(10, 55, 0x40, 20)
+
+	f := &File{
+		Header: &Header{Format: 1, NumberOfTracks: 2, Division: 96},
+		Tracks: []*Track{a.File().Tracks[0], b.File().Tracks[0]},
+	}
+
+	want := []Note{
+		{Key: 64, Velocity: 0x40, Start: 0, Duration: 10},
+		{Key: 55, Velocity: 0x40, Start: 10, Duration: 20},
+		{Key: 60, Velocity: 0x40, Start: 10, Duration: 10},
+	}
+	if got := f.Notes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Notes() = %v want %v", got, want)
+	}
+}
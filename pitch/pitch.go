@@ -0,0 +1,172 @@
+// Package pitch converts between MIDI key numbers, note names such as
+// "C#4" or "Eb3", and frequencies.
+package pitch
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	MiddleC = 60
+	A4      = 69
+
+	// StandardTuning is the conventional frequency of A4 in Hz.
+	StandardTuning = 440.0
+)
+
+type Spelling int
+
+const (
+	Sharps Spelling = iota
+	Flats
+)
+
+var (
+	sharpNames = []string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+	flatNames  = []string{"C", "Db", "D", "Eb", "E", "F", "Gb", "G", "Ab", "A", "Bb", "B"}
+
+	letterPitchClasses = map[byte]int{
+		'C': 0,
+		'D': 2,
+		'E': 4,
+		'F': 5,
+		'G': 7,
+		'A': 9,
+		'B': 11,
+	}
+)
+
+// Notation describes how octaves are numbered and how black keys are
+// spelled. Conventions differ on the octave of middle C: scientific pitch
+// notation calls it C4, while many Yamaha instruments call it C3.
+type Notation struct {
+	MiddleCOctave int
+	Spelling      Spelling
+}
+
+var (
+	Scientific = Notation{MiddleCOctave: 4}
+	Yamaha     = Notation{MiddleCOctave: 3}
+)
+
+// PitchClass returns the key's position within its octave, 0 being C.
+func PitchClass(key int) int {
+	pc := key % 12
+	if pc < 0 {
+		pc += 12
+	}
+	return pc
+}
+
+// PitchClassName names a pitch class without an octave.
+func PitchClassName(pc int, spelling Spelling) string {
+	if spelling == Flats {
+		return flatNames[PitchClass(pc)]
+	}
+	return sharpNames[PitchClass(pc)]
+}
+
+// ParsePitchClass parses a letter name with optional accidentals, such
+// as "F#", "Bb" or "Cx", returning the number of consumed bytes.
+// The result is not reduced modulo 12, so "Cb" is -1 and "B#" is 12.
+func ParsePitchClass(s string) (int, int, error) {
+	if s == "" {
+		return 0, 0, fmt.Errorf("empty note name")
+	}
+
+	pc, ok := letterPitchClasses[strings.ToUpper(s[:1])[0]]
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid note letter in %q", s)
+	}
+
+	i := 1
+	for i < len(s) {
+		switch {
+		case s[i] == '#':
+			pc++
+			i++
+		case s[i] == 'x':
+			pc += 2
+			i++
+		case s[i] == 'b':
+			pc--
+			i++
+		case strings.HasPrefix(s[i:], "♯"):
+			pc++
+			i += len("♯")
+		case strings.HasPrefix(s[i:], "♭"):
+			pc--
+			i += len("♭")
+		default:
+			return pc, i, nil
+		}
+	}
+
+	return pc, i, nil
+}
+
+// Parse parses a note name such as "C#4", "Eb-1" or "A♭3" into a MIDI key.
+func (n Notation) Parse(s string) (int, error) {
+	pc, consumed, err := ParsePitchClass(s)
+	if err != nil {
+		return 0, err
+	}
+
+	octaveString := s[consumed:]
+	if octaveString == "" {
+		return 0, fmt.Errorf("missing octave in note name %q", s)
+	}
+
+	octave, err := strconv.Atoi(octaveString)
+	if err != nil {
+		return 0, fmt.Errorf("invalid octave in note name %q", s)
+	}
+
+	key := MiddleC + 12*(octave-n.MiddleCOctave) + pc
+	if key < 0 || key > 127 {
+		return 0, fmt.Errorf("note %q out of MIDI range (key %d)", s, key)
+	}
+
+	return key, nil
+}
+
+// Name formats a MIDI key as a note name such as "C#4".
+func (n Notation) Name(key int) string {
+	octave := n.MiddleCOctave + floorDiv(key-MiddleC, 12)
+	return fmt.Sprintf("%s%d", PitchClassName(key, n.Spelling), octave)
+}
+
+// Parse parses a note name in scientific pitch notation.
+func Parse(s string) (int, error) {
+	return Scientific.Parse(s)
+}
+
+// Name formats a MIDI key in scientific pitch notation, using sharps.
+func Name(key int) string {
+	return Scientific.Name(key)
+}
+
+// Frequency returns the equal-tempered frequency of a key in Hz, given
+// the frequency of A4.
+func Frequency(key int, a4 float64) float64 {
+	return a4 * math.Pow(2, float64(key-A4)/12)
+}
+
+// FromFrequency returns the nearest key to a frequency in Hz, given the
+// frequency of A4, and the deviation from that key in cents.
+func FromFrequency(freq float64, a4 float64) (int, float64) {
+	semitones := 12 * math.Log2(freq/a4)
+	nearest := math.Floor(semitones + 0.5)
+	return A4 + int(nearest), 100 * (semitones - nearest)
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,85 @@
+package pitch
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	testcases := []struct {
+		notation Notation
+		name     string
+		want     int
+	}{
+		{Scientific, "C4", 60},
+		{Scientific, "c4", 60},
+		{Scientific, "C#4", 61},
+		{Scientific, "Db4", 61},
+		{Scientific, "Eb3", 51},
+		{Scientific, "B#3", 60},
+		{Scientific, "Cb4", 59},
+		{Scientific, "Fx4", 67},
+		{Scientific, "A♭3", 56},
+		{Scientific, "C-1", 0},
+		{Scientific, "G9", 127},
+		{Yamaha, "C3", 60},
+		{Yamaha, "C-2", 0},
+	}
+
+	for _, testcase := range testcases {
+		got, err := testcase.notation.Parse(testcase.name)
+		if err != nil {
+			t.Errorf("Parse(%q) = err: %v", testcase.name, err)
+			continue
+		}
+		if got != testcase.want {
+			t.Errorf("Parse(%q) = %d want %d", testcase.name, got, testcase.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, name := range []string{"", "H4", "C", "C#x", "G#9", "C-2"} {
+		if key, err := Parse(name); err == nil {
+			t.Errorf("Parse(%q) = %d want error", name, key)
+		}
+	}
+}
+
+func TestNameRoundtrip(t *testing.T) {
+	notations := []Notation{
+		Scientific,
+		Yamaha,
+		{MiddleCOctave: 4, Spelling: Flats},
+	}
+	for _, notation := range notations {
+		for key := 0; key < 128; key++ {
+			name := notation.Name(key)
+			got, err := notation.Parse(name)
+			if err != nil || got != key {
+				t.Errorf("%v: Parse(Name(%d) = %q) = %d, %v", notation, key, name, got, err)
+			}
+		}
+	}
+
+	if got := Name(0); got != "C-1" {
+		t.Errorf("Name(0) = %q want %q", got, "C-1")
+	}
+}
+
+func TestFrequency(t *testing.T) {
+	if got := Frequency(A4, StandardTuning); got != 440 {
+		t.Errorf("Frequency(A4, 440) = %v want 440", got)
+	}
+	if got := Frequency(MiddleC, StandardTuning); math.Abs(got-261.6256) > 1e-3 {
+		t.Errorf("Frequency(MiddleC, 440) = %v want 261.6256", got)
+	}
+	if got := Frequency(A4+12, 432); got != 864 {
+		t.Errorf("Frequency(A5, 432) = %v want 864", got)
+	}
+
+	key, cents := FromFrequency(445, StandardTuning)
+	if key != A4 || math.Abs(cents-19.56) > 0.01 {
+		t.Errorf("FromFrequency(445, 440) = %d, %v want %d, 19.56", key, cents, A4)
+	}
+}
@@ -0,0 +1,103 @@
+package player
+
+import (
+	"time"
+
+	"github.com/steinarvk/midi"
+	"github.com/steinarvk/midi/wire"
+)
+
+// pulsesPerQuarter is the rate of MIDI timing clock.
+const pulsesPerQuarter = 24
+
+// pulsesPerSixteenth is the number of timing clocks in a MIDI beat, the
+// unit of Song Position Pointer.
+const pulsesPerSixteenth = pulsesPerQuarter / 4
+
+// maxSongPosition is the largest Song Position Pointer there is.
+const maxSongPosition = 0x3FFF
+
+// SetClockOutput makes the player a clock master, or stops it being one.
+// As a clock master, it sends timing clock at 24 pulses to the
+// quarter-note following the tempo map (or at the default tempo, for
+// files with SMPTE divisions), and Start, Continue and Stop as playback
+// starts, resumes and stops. On seeking and looping, it sends Song
+// Position Pointer for the first sixteenth note at or after the new
+// position, and carries on with timing clock from there.
+func (p *Player) SetClockOutput(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if enabled == p.sendClock {
+		return
+	}
+	if !enabled {
+		if p.state == playing {
+			p.send(wire.Stop, -1)
+		}
+		p.sendClock = false
+		return
+	}
+
+	p.sendClock = true
+	if p.state == stopped && p.originAt == 0 {
+		p.nextPulse = 0
+		return
+	}
+
+	p.originAt = p.at()
+	p.origin = p.clock.Now()
+	p.syncClock(p.tempo.Tick(p.originAt))
+	p.signal()
+}
+
+// syncClock tells slaves to move to the first sixteenth note at or after
+// a tick. While playing, it stops them first and continues them after;
+// otherwise Play continues them.
+func (p *Player) syncClock(tick int64) {
+	if !p.sendClock {
+		return
+	}
+
+	// Past the furthest position slaves can be told of, they are left at
+	// it, while timing clock carries on from where playback really is.
+	position := p.sixteenth(tick)
+	p.nextPulse = position * pulsesPerSixteenth
+	if position > maxSongPosition {
+		position = maxSongPosition
+	}
+
+	if p.state == playing {
+		p.send(wire.Stop, -1)
+	}
+	p.send(wire.SongPosition(position), -1)
+	if p.state == playing {
+		p.send(wire.Continue, -1)
+	}
+}
+
+// sixteenth returns the number of the first sixteenth note at or after
+// a tick.
+func (p *Player) sixteenth(tick int64) int64 {
+	if p.division < 0 {
+		return int64((p.tempo.Time(tick) + p.pulseTime(pulsesPerSixteenth) - 1) / p.pulseTime(pulsesPerSixteenth))
+	}
+	return (tick*4 + p.division - 1) / p.division
+}
+
+// pulseTime returns the point in the file at which a timing clock
+// falls, interpolating between ticks when the division is not a multiple
+// of 24.
+func (p *Player) pulseTime(n int64) time.Duration {
+	if p.division < 0 {
+		return time.Duration(n * midi.DefaultTempo * int64(time.Microsecond) / pulsesPerQuarter)
+	}
+
+	ticks := n * p.division
+	tick, rem := ticks/pulsesPerQuarter, ticks%pulsesPerQuarter
+	t := p.tempo.Time(tick)
+	if rem > 0 {
+		t += (p.tempo.Time(tick+1) - t) * time.Duration(rem) / pulsesPerQuarter
+	}
+	return t
+}
@@ -0,0 +1,182 @@
+package player
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/steinarvk/midi"
+)
+
+// withoutClock counts and leaves out the timing clock messages.
+func withoutClock(sent []string) ([]string, int) {
+	var rv []string
+	pulses := 0
+	for _, s := range sent {
+		if strings.HasSuffix(s, " TimingClock") {
+			pulses++
+			continue
+		}
+		rv = append(rv, s)
+	}
+	return rv, pulses
+}
+
+func expectClocked(t *testing.T, out *recorder, pulses int, want ...string) {
+	t.Helper()
+	got, n := withoutClock(out.take())
+	if n != pulses {
+		t.Errorf("sent %d timing clock message(s) want %d", n, pulses)
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("sent %q want %q", got, want)
+	}
+}
+
+func TestClockOutput(t *testing.T) {
+	p, clock, out := newTestPlayer(t)
+	p.SetClockOutput(true)
+
+	p.Play()
+	clock.BlockUntil(1)
+	if got := out.take(); len(got) < 2 || got[0] != "0s Start" || got[1] != "0s TimingClock" {
+		t.Errorf("sent %q want Start and TimingClock first", got)
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	clock.BlockUntil(1)
+	expectClocked(t, out, 24,
+		"500ms MIDI ch=0 NoteOff k=C4 v=40",
+		"500ms MIDI ch=0 ProgramChange 06",
+		"500ms MIDI ch=0 NoteOn k=D4 v=40",
+	)
+
+	clock.Advance(100 * time.Millisecond)
+	clock.BlockUntil(1)
+	p.Pause()
+	expectClocked(t, out, 4, "600ms MIDI ch=0 NoteOff k=D4 v=00", "600ms Stop")
+
+	p.Resume()
+	clock.BlockUntil(1)
+	expectClocked(t, out, 0, "600ms Continue")
+
+	clock.Advance(400 * time.Millisecond)
+	if err := p.Wait(); err != nil {
+		t.Errorf("Wait() = err: %v", err)
+	}
+	expectClocked(t, out, 19, "1s MIDI ch=0 NoteOff k=D4 v=40", "1s Stop")
+}
+
+func TestClockSongPosition(t *testing.T) {
+	p, clock, out := newTestPlayer(t)
+	p.SetClockOutput(true)
+
+	p.SeekTick(470)
+	expectClocked(t, out, 0,
+		"0s SongPosition 4",
+		"0s MIDI ch=0 ProgramChange 05",
+		"0s MIDI ch=0 ControllerChange/ChannelMode 07 64",
+	)
+
+	p.Play()
+	clock.BlockUntil(1)
+	expectClocked(t, out, 0, "0s Continue")
+
+	// The first clock comes at the sixteenth note the pointer gave.
+	clock.Advance(10416667 * time.Nanosecond)
+	clock.BlockUntil(1)
+	expectClocked(t, out, 1,
+		"10.416667ms MIDI ch=0 NoteOff k=C4 v=40",
+		"10.416667ms MIDI ch=0 ProgramChange 06",
+		"10.416667ms MIDI ch=0 NoteOn k=D4 v=40",
+	)
+
+	p.SeekTick(10)
+	clock.BlockUntil(1)
+	expectClocked(t, out, 0,
+		"10.416667ms MIDI ch=0 NoteOff k=D4 v=00",
+		"10.416667ms Stop",
+		"10.416667ms SongPosition 1",
+		"10.416667ms Continue",
+		"10.416667ms MIDI ch=0 ProgramChange 05",
+		"10.416667ms MIDI ch=0 ControllerChange/ChannelMode 07 64",
+	)
+
+	p.Stop()
+	p.Wait()
+	got, _ := withoutClock(out.take())
+	if len(got) == 0 || got[0] != "10.416667ms Stop" {
+		t.Errorf("on Stop, sent %q want Stop first", got)
+	}
+}
+
+func TestClockPastLastSongPosition(t *testing.T) {
+	w := midi.NewSimpleWriter(480)
+	w.NoteAt(0, 60, 64, 2400000)
+
+	clock := NewFakeClock()
+	out := &recorder{clock: clock}
+	p, err := New(w.File(), out, clock)
+	if err != nil {
+		t.Fatalf("New() = err: %v", err)
+	}
+	p.SetClockOutput(true)
+
+	p.SeekTick(2000000)
+	expectClocked(t, out, 0, "0s SongPosition 16383")
+
+	// Clock goes on from where playback is, not from the pointer.
+	p.Play()
+	clock.BlockUntil(1)
+	expectClocked(t, out, 0, "0s Continue")
+
+	// The first clock is at the next sixteenth note, 40 ticks on.
+	clock.Advance(500 * time.Millisecond)
+	clock.BlockUntil(1)
+	expectClocked(t, out, 23)
+
+	p.Stop()
+	p.Wait()
+}
+
+func TestClockLoop(t *testing.T) {
+	p, clock, out := newTestPlayer(t)
+	p.SetClockOutput(true)
+	if err := p.SetLoop(0, 480); err != nil {
+		t.Fatalf("SetLoop(0, 480) = err: %v", err)
+	}
+
+	p.Play()
+	clock.BlockUntil(1)
+	out.take()
+
+	clock.Advance(500 * time.Millisecond)
+	clock.BlockUntil(1)
+	expectClocked(t, out, 24,
+		"500ms MIDI ch=0 NoteOff k=C4 v=00",
+		"500ms Stop",
+		"500ms SongPosition 0",
+		"500ms Continue",
+		"500ms MIDI ch=0 ProgramChange 05",
+		"500ms MIDI ch=0 ControllerChange/ChannelMode 07 64",
+		"500ms MIDI ch=0 NoteOn k=C4 v=40",
+	)
+
+	p.Stop()
+	p.Wait()
+}
+
+func TestPulseTime(t *testing.T) {
+	for _, division := range []int16{480, 100, -25<<8 | 40} {
+		p, err := New(&midi.File{Header: &midi.Header{Division: division}}, &recorder{clock: SystemClock}, SystemClock)
+		if err != nil {
+			t.Fatalf("New() = err: %v", err)
+		}
+		for _, n := range []int64{1, 7, 24, 100} {
+			want := time.Duration(n) * 500 * time.Millisecond / 24
+			if got := p.pulseTime(n); got-want > time.Microsecond || want-got > time.Microsecond {
+				t.Errorf("division %d: pulseTime(%d) = %v want %v", division, n, got, want)
+			}
+		}
+	}
+}
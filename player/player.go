@@ -0,0 +1,504 @@
+// Package player plays files in real time, sending their channel and
+// sysex messages, and optionally MIDI clock for slave devices, to an
+// output such as a wire.Writer on a MIDI device.
+package player
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/steinarvk/midi"
+	"github.com/steinarvk/midi/wire"
+)
+
+// Output receives the messages a player sends. A *wire.Writer is one.
+type Output interface {
+	Write(m wire.Message) error
+}
+
+type state int
+
+const (
+	stopped state = iota
+	playing
+	paused
+)
+
+// allNotesOff is the controller that silences a channel.
+const allNotesOff = 123
+
+type event struct {
+	tick  int64
+	track int
+	msg   wire.Message
+}
+
+// Player plays a file. Its methods may be called from any goroutine,
+// while it plays in a goroutine of its own.
+type Player struct {
+	out   Output
+	clock Clock
+	tempo *midi.TempoMap
+
+	file     *midi.File
+	division int64
+
+	// events are the messages to send, in order, and end is the tick of
+	// the last event of the file, at which playback ends.
+	events []event
+	end    int64
+
+	mu    sync.Mutex
+	state state
+	next  int
+
+	// While playing, the point originAt into the file (at the file's own
+	// tempo) is played at origin. Otherwise originAt is the point from
+	// which playback continues.
+	originAt time.Duration
+	origin   time.Time
+
+	// scale is the factor by which the tempo is scaled.
+	scale float64
+
+	// Playback jumps back to loopStart on reaching loopEnd, when loopEnd
+	// is set and playback started before it.
+	loopStart, loopEnd int64
+
+	mutedTracks, soloTracks     map[int]bool
+	mutedChannels, soloChannels [16]bool
+
+	// With sendClock, nextPulse is the number of the next timing clock
+	// message, counting 24 to the quarter-note from the start.
+	sendClock bool
+	nextPulse int64
+
+	// sounding holds, for each channel and key, one more than the track
+	// whose note is sounding, or zero.
+	sounding [16][128]int
+	err      error
+
+	running bool
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+// New creates a player for a file of format 0 or 1, which stands
+// stopped at its start.
+func New(f *midi.File, out Output, clock Clock) (*Player, error) {
+	if f.Header.Format == 2 {
+		return nil, errors.New("format 2 files are unsupported")
+	}
+
+	tempo, err := midi.NewTempoMap(f)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Player{
+		out:         out,
+		clock:       clock,
+		tempo:       tempo,
+		file:        f,
+		division:    int64(f.Header.Division),
+		scale:       1,
+		mutedTracks: map[int]bool{},
+		soloTracks:  map[int]bool{},
+		wake:        make(chan struct{}, 1),
+	}
+
+	for i, track := range f.Tracks {
+		if l := track.Length(); l > p.end {
+			p.end = l
+		}
+		for _, te := range track.TimedEvents() {
+			switch te.Event.(type) {
+			case midi.MIDIEvent, midi.SysexEvent:
+				p.events = append(p.events, event{tick: te.Tick, track: i, msg: te.Event})
+			}
+		}
+	}
+	sort.SliceStable(p.events, func(i, j int) bool { return p.events[i].tick < p.events[j].tick })
+
+	return p, nil
+}
+
+// Play starts or resumes playback from the current position.
+func (p *Player) Play() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state == playing {
+		return
+	}
+	if p.sendClock {
+		if p.originAt == 0 {
+			p.nextPulse = 0
+			p.send(wire.Start, -1)
+		} else {
+			p.send(wire.Continue, -1)
+		}
+	}
+
+	p.state = playing
+	p.err = nil
+	p.origin = p.clock.Now()
+
+	if !p.running {
+		p.running = true
+		p.done = make(chan struct{})
+		go p.run(p.done)
+	}
+	p.signal()
+}
+
+// Pause stops playback where it is, releasing the notes that are
+// sounding, until Resume or Play.
+func (p *Player) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state != playing {
+		return
+	}
+	p.originAt = p.at()
+	p.state = paused
+	p.silence()
+	if p.sendClock {
+		p.send(wire.Stop, -1)
+	}
+	p.signal()
+}
+
+// Resume continues playback after Pause.
+func (p *Player) Resume() {
+	p.mu.Lock()
+	wasPaused := p.state == paused
+	p.mu.Unlock()
+
+	if wasPaused {
+		p.Play()
+	}
+}
+
+// Stop stops playback, sending NoteOff for the notes that are sounding
+// and All Notes Off on every channel, and rewinds to the start.
+func (p *Player) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.sendClock && p.state != stopped {
+		p.send(wire.Stop, -1)
+	}
+	p.state = stopped
+	p.silence()
+	for channel := 0; channel < 16; channel++ {
+		p.send(midi.MIDIEvent{
+			Type:             midi.ControllerChange,
+			Channel:          channel,
+			ControllerNumber: allNotesOff,
+		}, -1)
+	}
+	p.next = 0
+	p.originAt = 0
+	p.nextPulse = 0
+	p.signal()
+}
+
+// SeekTick moves playback to a tick, releasing the notes that are
+// sounding and sending the latest program, controller, pressure and pitch
+// bend messages before the tick on each channel, so that playback from
+// there sounds as it would have. When sending MIDI clock, it also sends
+// Song Position Pointer.
+func (p *Player) SeekTick(tick int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if tick < 0 {
+		tick = 0
+	}
+	p.origin = p.clock.Now()
+	p.moveTo(tick)
+	p.chase()
+	p.signal()
+}
+
+// moveTo moves playback to a tick, to carry on from there at origin.
+func (p *Player) moveTo(tick int64) {
+	p.silence()
+	p.next = sort.Search(len(p.events), func(i int) bool { return p.events[i].tick >= tick })
+	p.originAt = p.tempo.Time(tick)
+	p.syncClock(tick)
+}
+
+// Position returns the tick playback has reached.
+func (p *Player) Position() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.position()
+}
+
+// Wait waits until playback ends, either at the end of the file or with
+// Stop, and returns the error the output failed with, if any.
+func (p *Player) Wait() error {
+	p.mu.Lock()
+	done := p.done
+	p.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func (p *Player) position() int64 {
+	return p.tempo.Tick(p.at())
+}
+
+// at returns the point playback has reached, at the file's own tempo.
+func (p *Player) at() time.Duration {
+	if p.state != playing {
+		return p.originAt
+	}
+	elapsed := p.clock.Now().Sub(p.origin)
+	if elapsed <= 0 {
+		return p.originAt
+	}
+	return p.originAt + time.Duration(float64(elapsed)*p.scale)
+}
+
+// due returns the time at which a point in the file is played.
+func (p *Player) due(at time.Duration) time.Time {
+	return p.origin.Add(time.Duration(float64(at-p.originAt) / p.scale))
+}
+
+// looping checks whether playback is to jump back at the loop's end.
+func (p *Player) looping() bool {
+	return p.loopEnd > 0 && p.originAt < p.tempo.Time(p.loopEnd)
+}
+
+func (p *Player) signal() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Player) run(done chan struct{}) {
+	defer close(done)
+
+	for {
+		p.mu.Lock()
+		switch p.state {
+		case stopped:
+			p.running = false
+			p.mu.Unlock()
+			return
+		case paused:
+			p.mu.Unlock()
+			<-p.wake
+			continue
+		}
+
+		_, at := p.nextStep()
+		wait := p.due(at).Sub(p.clock.Now())
+		p.mu.Unlock()
+
+		if wait > 0 {
+			timer := p.clock.NewTimer(wait)
+			select {
+			case <-timer.C():
+			case <-p.wake:
+				timer.Stop()
+				continue
+			}
+		}
+
+		p.mu.Lock()
+		p.play()
+		p.mu.Unlock()
+	}
+}
+
+type step int
+
+const (
+	stepPulse step = iota
+	stepEvent
+	stepLoop
+	stepEnd
+)
+
+// nextStep returns what playback does next, and at which point in the
+// file. Timing clock comes before events at the same point, and both
+// before the end of the loop or of the file.
+func (p *Player) nextStep() (step, time.Duration) {
+	looping := p.looping()
+	limit := p.tempo.Time(p.end)
+	if looping {
+		limit = p.tempo.Time(p.loopEnd)
+	}
+
+	var best step
+	var at time.Duration
+	found := false
+	consider := func(s step, t time.Duration) {
+		if !found || t < at {
+			best, at, found = s, t, true
+		}
+	}
+
+	if p.sendClock {
+		if t := p.pulseTime(p.nextPulse); t < limit {
+			consider(stepPulse, t)
+		}
+	}
+	if p.next < len(p.events) && (!looping || p.events[p.next].tick < p.loopEnd) {
+		consider(stepEvent, p.tempo.Time(p.events[p.next].tick))
+	}
+	if looping {
+		consider(stepLoop, limit)
+	} else if p.next == len(p.events) {
+		consider(stepEnd, limit)
+	}
+	return best, at
+}
+
+// play takes every step that is due: sending timing clock and events,
+// jumping back at the end of the loop, and ending playback at the end of
+// the file.
+func (p *Player) play() {
+	now := p.clock.Now()
+	for p.state == playing {
+		s, at := p.nextStep()
+		if p.due(at).After(now) {
+			return
+		}
+
+		switch s {
+		case stepPulse:
+			p.send(wire.TimingClock, -1)
+			p.nextPulse++
+
+		case stepEvent:
+			p.playEvent(p.events[p.next])
+			p.next++
+
+		case stepLoop:
+			p.origin = p.due(at)
+			p.moveTo(p.loopStart)
+
+		case stepEnd:
+			if p.sendClock {
+				p.send(wire.Stop, -1)
+			}
+			p.state = stopped
+			p.silence()
+			p.next = 0
+			p.originAt = 0
+			p.nextPulse = 0
+		}
+	}
+}
+
+// playEvent sends an event from the file, unless it strikes a note on a
+// muted track or channel. Muting leaves other messages alone, so that
+// the instruments are set up right when notes are heard again, except
+// for NoteOff, which is only sent if the track's own note is sounding.
+func (p *Player) playEvent(e event) {
+	if evt, ok := e.msg.(midi.MIDIEvent); ok && !p.audible(e.track, evt.Channel) {
+		switch evt.Type {
+		case midi.NoteOn:
+			return
+		case midi.NoteOff:
+			if evt.Channel < 0 || evt.Channel >= 16 || evt.Key < 0 || evt.Key >= 128 || p.sounding[evt.Channel][evt.Key] != e.track+1 {
+				return
+			}
+		}
+	}
+	p.send(e.msg, e.track)
+}
+
+// send sends a message from a track (or -1 for none), keeping track of
+// the notes that are sounding. When the output fails, playback stops.
+func (p *Player) send(m wire.Message, track int) {
+	if p.err != nil {
+		return
+	}
+
+	if evt, ok := m.(midi.MIDIEvent); ok && evt.Channel >= 0 && evt.Channel < 16 && evt.Key >= 0 && evt.Key < 128 {
+		switch evt.Type {
+		case midi.NoteOn:
+			p.sounding[evt.Channel][evt.Key] = track + 1
+		case midi.NoteOff:
+			p.sounding[evt.Channel][evt.Key] = 0
+		}
+	}
+
+	if err := p.out.Write(m); err != nil {
+		p.err = err
+		p.state = stopped
+		p.signal()
+	}
+}
+
+// silence sends NoteOff for every note that is sounding.
+func (p *Player) silence() {
+	p.release(func(track, channel int) bool { return true })
+}
+
+// release sends NoteOff for the notes sounding from the given tracks
+// and channels.
+func (p *Player) release(match func(track, channel int) bool) {
+	for channel := range p.sounding {
+		for key, from := range p.sounding[channel] {
+			if from > 0 && match(from-1, channel) {
+				p.send(midi.MIDIEvent{Type: midi.NoteOff, Channel: channel, Key: key}, -1)
+			}
+		}
+	}
+}
+
+// chase sends the latest state-setting messages on each channel before
+// the position.
+func (p *Player) chase() {
+	type slot struct {
+		channel    int
+		status     midi.MIDIEventType
+		controller int
+	}
+	latest := map[slot]int{}
+
+	for i := 0; i < p.next; i++ {
+		evt, ok := p.events[i].msg.(midi.MIDIEvent)
+		if !ok {
+			continue
+		}
+		s := slot{channel: evt.Channel, status: evt.Type}
+		switch evt.Type {
+		case midi.ControllerChange:
+			if evt.ControllerNumber >= 120 {
+				continue
+			}
+			s.controller = evt.ControllerNumber
+		case midi.ProgramChange, midi.ChannelPressure, midi.PitchBend:
+		default:
+			continue
+		}
+		latest[s] = i
+	}
+
+	var indices []int
+	for _, i := range latest {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	for _, i := range indices {
+		p.send(p.events[i].msg, p.events[i].track)
+	}
+}
@@ -0,0 +1,172 @@
+package player
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/steinarvk/midi"
+	"github.com/steinarvk/midi/wire"
+)
+
+// recorder is an output that remembers what it was sent, and when.
+type recorder struct {
+	clock Clock
+
+	mu   sync.Mutex
+	sent []string
+}
+
+func (r *recorder) Write(m wire.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, fmt.Sprintf("%v %v", r.clock.Now().Sub(NewFakeClock().Now()), m))
+	return nil
+}
+
+// take returns what was sent since the last call.
+func (r *recorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rv := r.sent
+	r.sent = nil
+	return rv
+}
+
+func testFile() *midi.File {
+	w := midi.NewSimpleWriter(480)
+	w.EventAt(0, midi.MIDIEvent{Type: midi.ProgramChange, ProgramNumber: 5})
+	w.EventAt(0, midi.MIDIEvent{Type: midi.ControllerChange, ControllerNumber: 7, ControllerValue: 100})
+	w.NoteAt(0, 60, 64, 480)
+	w.EventAt(480, midi.MIDIEvent{Type: midi.ProgramChange, ProgramNumber: 6})
+	w.NoteAt(480, 62, 64, 480)
+	return w.File()
+}
+
+func newTestPlayer(t *testing.T) (*Player, *FakeClock, *recorder) {
+	clock := NewFakeClock()
+	out := &recorder{clock: clock}
+	p, err := New(testFile(), out, clock)
+	if err != nil {
+		t.Fatalf("New() = err: %v", err)
+	}
+	return p, clock, out
+}
+
+func expectSent(t *testing.T, out *recorder, want ...string) {
+	t.Helper()
+	if got := out.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %q want %q", got, want)
+	}
+}
+
+func TestPlay(t *testing.T) {
+	p, clock, out := newTestPlayer(t)
+
+	p.Play()
+	clock.BlockUntil(1)
+	expectSent(t, out,
+		"0s MIDI ch=0 ProgramChange 05",
+		"0s MIDI ch=0 ControllerChange/ChannelMode 07 64",
+		"0s MIDI ch=0 NoteOn k=C4 v=40",
+	)
+
+	clock.Advance(500 * time.Millisecond)
+	clock.BlockUntil(1)
+	expectSent(t, out,
+		"500ms MIDI ch=0 NoteOff k=C4 v=40",
+		"500ms MIDI ch=0 ProgramChange 06",
+		"500ms MIDI ch=0 NoteOn k=D4 v=40",
+	)
+
+	clock.Advance(500 * time.Millisecond)
+	if err := p.Wait(); err != nil {
+		t.Errorf("Wait() = err: %v", err)
+	}
+	expectSent(t, out, "1s MIDI ch=0 NoteOff k=D4 v=40")
+
+	if pos := p.Position(); pos != 0 {
+		t.Errorf("Position() = %d want 0", pos)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	p, clock, out := newTestPlayer(t)
+
+	p.Play()
+	clock.BlockUntil(1)
+	out.take()
+
+	clock.Advance(250 * time.Millisecond)
+	p.Pause()
+	expectSent(t, out, "250ms MIDI ch=0 NoteOff k=C4 v=00")
+	if pos := p.Position(); pos != 240 {
+		t.Errorf("Position() = %d want 240", pos)
+	}
+
+	clock.Advance(10 * time.Second)
+	p.Resume()
+	clock.BlockUntil(1)
+	expectSent(t, out)
+
+	clock.Advance(250 * time.Millisecond)
+	clock.BlockUntil(1)
+	expectSent(t, out,
+		"10.5s MIDI ch=0 NoteOff k=C4 v=40",
+		"10.5s MIDI ch=0 ProgramChange 06",
+		"10.5s MIDI ch=0 NoteOn k=D4 v=40",
+	)
+	if pos := p.Position(); pos != 480 {
+		t.Errorf("Position() = %d want 480", pos)
+	}
+
+	p.Stop()
+	p.Wait()
+}
+
+func TestStop(t *testing.T) {
+	p, clock, out := newTestPlayer(t)
+
+	p.Play()
+	clock.BlockUntil(1)
+	out.take()
+
+	clock.Advance(100 * time.Millisecond)
+	p.Stop()
+	if err := p.Wait(); err != nil {
+		t.Errorf("Wait() = err: %v", err)
+	}
+
+	want := []string{"100ms MIDI ch=0 NoteOff k=C4 v=00"}
+	for channel := 0; channel < 16; channel++ {
+		want = append(want, fmt.Sprintf("100ms MIDI ch=%d ControllerChange/ChannelMode 7b 00", channel))
+	}
+	expectSent(t, out, want...)
+
+	if pos := p.Position(); pos != 0 {
+		t.Errorf("Position() = %d want 0", pos)
+	}
+}
+
+func TestSeek(t *testing.T) {
+	p, clock, out := newTestPlayer(t)
+
+	p.SeekTick(720)
+	expectSent(t, out,
+		"0s MIDI ch=0 ControllerChange/ChannelMode 07 64",
+		"0s MIDI ch=0 ProgramChange 06",
+	)
+
+	p.Play()
+	clock.BlockUntil(1)
+	expectSent(t, out)
+	if pos := p.Position(); pos != 720 {
+		t.Errorf("Position() = %d want 720", pos)
+	}
+
+	clock.Advance(250 * time.Millisecond)
+	p.Wait()
+	expectSent(t, out, "250ms MIDI ch=0 NoteOff k=D4 v=40")
+}
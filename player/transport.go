@@ -0,0 +1,143 @@
+package player
+
+import (
+	"fmt"
+
+	"github.com/steinarvk/midi/score"
+)
+
+// SetLoop makes playback jump back to the start tick on reaching the end
+// tick, if it started before the end.
+func (p *Player) SetLoop(start, end int64) error {
+	if start < 0 || end <= start {
+		return fmt.Errorf("invalid loop from tick %d to %d", start, end)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Whether to loop depends on where playback is now.
+	p.originAt = p.at()
+	p.origin = p.clock.Now()
+	p.loopStart = start
+	p.loopEnd = end
+	p.signal()
+	return nil
+}
+
+// SetLoopBars loops from the start of one bar to the end of another,
+// counting bars from 1 following the file's time signatures.
+func (p *Player) SetLoopBars(first, last int) error {
+	if first < 1 || last < first {
+		return fmt.Errorf("invalid loop from bar %d to %d", first, last)
+	}
+
+	bars, err := score.Bars(p.file, p.end+1)
+	if err != nil {
+		return err
+	}
+	if last > len(bars) {
+		return fmt.Errorf("no such bar: %d (there are %d bars)", last, len(bars))
+	}
+
+	end := bars[last-1]
+	return p.SetLoop(bars[first-1].Start, end.Start+end.Length)
+}
+
+// ClearLoop stops looping, so that playback carries on to the end.
+func (p *Player) ClearLoop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.loopStart = 0
+	p.loopEnd = 0
+	p.signal()
+}
+
+// SetTempoScale plays the file faster (for factors above 1) or slower
+// than its tempo map says, from now on.
+func (p *Player) SetTempoScale(factor float64) error {
+	if factor <= 0 {
+		return fmt.Errorf("invalid tempo scale %v", factor)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.originAt = p.at()
+	p.origin = p.clock.Now()
+	p.scale = factor
+	p.signal()
+	return nil
+}
+
+// MuteTrack mutes or unmutes a track, releasing the notes of the track
+// that are sounding.
+func (p *Player) MuteTrack(track int, muted bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.mutedTracks[track] = muted
+	p.releaseInaudible()
+}
+
+// SoloTrack solos a track, or stops soloing it. While any track or
+// channel is soloed, only the notes of soloed tracks and channels are
+// heard.
+func (p *Player) SoloTrack(track int, solo bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.soloTracks[track] = solo
+	p.releaseInaudible()
+}
+
+// MuteChannel mutes or unmutes a channel, releasing the notes on the
+// channel that are sounding.
+func (p *Player) MuteChannel(channel int, muted bool) {
+	if channel < 0 || channel >= 16 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.mutedChannels[channel] = muted
+	p.releaseInaudible()
+}
+
+// SoloChannel solos a channel, or stops soloing it, as SoloTrack does.
+func (p *Player) SoloChannel(channel int, solo bool) {
+	if channel < 0 || channel >= 16 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.soloChannels[channel] = solo
+	p.releaseInaudible()
+}
+
+// audible checks whether notes from a track on a channel are heard.
+func (p *Player) audible(track, channel int) bool {
+	if p.mutedTracks[track] || (channel >= 0 && channel < 16 && p.mutedChannels[channel]) {
+		return false
+	}
+
+	soloing := false
+	for _, solo := range p.soloTracks {
+		soloing = soloing || solo
+	}
+	for _, solo := range p.soloChannels {
+		soloing = soloing || solo
+	}
+	if !soloing {
+		return true
+	}
+	return p.soloTracks[track] || (channel >= 0 && channel < 16 && p.soloChannels[channel])
+}
+
+func (p *Player) releaseInaudible() {
+	p.release(func(track, channel int) bool { return !p.audible(track, channel) })
+}
@@ -0,0 +1,171 @@
+package player
+
+import (
+	"testing"
+	"time"
+
+	"github.com/steinarvk/midi"
+)
+
+func TestLoop(t *testing.T) {
+	p, clock, out := newTestPlayer(t)
+
+	if err := p.SetLoop(0, 480); err != nil {
+		t.Fatalf("SetLoop(0, 480) = err: %v", err)
+	}
+	p.Play()
+	clock.BlockUntil(1)
+	out.take()
+
+	for _, at := range []string{"500ms", "1s"} {
+		clock.Advance(500 * time.Millisecond)
+		clock.BlockUntil(1)
+		expectSent(t, out,
+			at+" MIDI ch=0 NoteOff k=C4 v=00",
+			at+" MIDI ch=0 ProgramChange 05",
+			at+" MIDI ch=0 ControllerChange/ChannelMode 07 64",
+			at+" MIDI ch=0 NoteOn k=C4 v=40",
+		)
+	}
+
+	p.ClearLoop()
+	clock.Advance(500 * time.Millisecond)
+	clock.BlockUntil(1)
+	expectSent(t, out,
+		"1.5s MIDI ch=0 NoteOff k=C4 v=40",
+		"1.5s MIDI ch=0 ProgramChange 06",
+		"1.5s MIDI ch=0 NoteOn k=D4 v=40",
+	)
+
+	p.Stop()
+	p.Wait()
+}
+
+func TestLoopBars(t *testing.T) {
+	p, _, _ := newTestPlayer(t)
+
+	if err := p.SetLoopBars(1, 1); err != nil {
+		t.Errorf("SetLoopBars(1, 1) = err: %v", err)
+	}
+	if p.loopStart != 0 || p.loopEnd != 1920 {
+		t.Errorf("SetLoopBars(1, 1) loops from %d to %d want 0 to 1920", p.loopStart, p.loopEnd)
+	}
+
+	for _, bars := range [][2]int{{0, 1}, {2, 1}, {1, 2}} {
+		if err := p.SetLoopBars(bars[0], bars[1]); err == nil {
+			t.Errorf("SetLoopBars(%d, %d) = nil want error", bars[0], bars[1])
+		}
+	}
+}
+
+func TestTempoScale(t *testing.T) {
+	p, clock, out := newTestPlayer(t)
+
+	p.Play()
+	clock.BlockUntil(1)
+	out.take()
+
+	clock.Advance(250 * time.Millisecond)
+	if err := p.SetTempoScale(0.5); err != nil {
+		t.Fatalf("SetTempoScale(0.5) = err: %v", err)
+	}
+	clock.BlockUntil(1)
+	clock.Advance(499 * time.Millisecond)
+	clock.BlockUntil(1)
+	expectSent(t, out)
+
+	clock.Advance(time.Millisecond)
+	clock.BlockUntil(1)
+	expectSent(t, out,
+		"750ms MIDI ch=0 NoteOff k=C4 v=40",
+		"750ms MIDI ch=0 ProgramChange 06",
+		"750ms MIDI ch=0 NoteOn k=D4 v=40",
+	)
+	if pos := p.Position(); pos != 480 {
+		t.Errorf("Position() = %d want 480", pos)
+	}
+
+	p.Stop()
+	p.Wait()
+}
+
+func TestMuteAndSolo(t *testing.T) {
+	f := testFile()
+	bass := midi.NewSimpleWriter(480)
+	bass.EventAt(0, midi.MIDIEvent{Type: midi.NoteOn, Channel: 1, Key: 36, Velocity: 64})
+	bass.EventAt(960, midi.MIDIEvent{Type: midi.NoteOff, Channel: 1, Key: 36, Velocity: 64})
+	f.Tracks = append(f.Tracks, bass.File().Tracks[0])
+	f.Header.Format = 1
+	f.Header.NumberOfTracks = 2
+
+	clock := NewFakeClock()
+	out := &recorder{clock: clock}
+	p, err := New(f, out, clock)
+	if err != nil {
+		t.Fatalf("New() = err: %v", err)
+	}
+
+	p.Play()
+	clock.BlockUntil(1)
+	out.take()
+
+	p.MuteTrack(0, true)
+	expectSent(t, out, "0s MIDI ch=0 NoteOff k=C4 v=00")
+
+	clock.Advance(500 * time.Millisecond)
+	clock.BlockUntil(1)
+	expectSent(t, out, "500ms MIDI ch=0 ProgramChange 06")
+
+	p.MuteTrack(0, false)
+	p.SoloChannel(0, true)
+	expectSent(t, out, "500ms MIDI ch=1 NoteOff k=C2 v=00")
+
+	p.SoloChannel(0, false)
+	p.MuteChannel(1, true)
+	expectSent(t, out)
+
+	clock.Advance(500 * time.Millisecond)
+	if err := p.Wait(); err != nil {
+		t.Errorf("Wait() = err: %v", err)
+	}
+	expectSent(t, out, "1s MIDI ch=0 NoteOff k=D4 v=40")
+}
+
+func TestMuteLeavesOtherTracksSounding(t *testing.T) {
+	f := testFile()
+	doubling := midi.NewSimpleWriter(480)
+	doubling.NoteAt(240, 60, 64, 720)
+	f.Tracks = append(f.Tracks, doubling.File().Tracks[0])
+	f.Header.Format = 1
+	f.Header.NumberOfTracks = 2
+
+	clock := NewFakeClock()
+	out := &recorder{clock: clock}
+	p, err := New(f, out, clock)
+	if err != nil {
+		t.Fatalf("New() = err: %v", err)
+	}
+
+	p.MuteTrack(0, true)
+	p.Play()
+	clock.BlockUntil(1)
+	expectSent(t, out,
+		"0s MIDI ch=0 ProgramChange 05",
+		"0s MIDI ch=0 ControllerChange/ChannelMode 07 64",
+	)
+
+	clock.Advance(250 * time.Millisecond)
+	clock.BlockUntil(1)
+	expectSent(t, out, "250ms MIDI ch=0 NoteOn k=C4 v=40")
+
+	// The muted track's C4 ends here, but the other track's goes on.
+	clock.Advance(250 * time.Millisecond)
+	clock.BlockUntil(1)
+	expectSent(t, out, "500ms MIDI ch=0 ProgramChange 06")
+
+	clock.Advance(500 * time.Millisecond)
+	if err := p.Wait(); err != nil {
+		t.Errorf("Wait() = err: %v", err)
+	}
+	expectSent(t, out, "1s MIDI ch=0 NoteOff k=C4 v=40")
+}
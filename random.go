@@ -0,0 +1,173 @@
+package midi
+
+import (
+	"math/rand"
+)
+
+// Generator produces random but valid files, for property-based tests.
+// The files are exactly as Parse would return them after encoding, so
+// that they survive a round trip unchanged.
+type Generator struct {
+	rng *rand.Rand
+
+	// MaxTracks and MaxEvents bound the number of tracks in a file and
+	// of events (not counting time deltas) in a track.
+	MaxTracks int
+	MaxEvents int
+
+	// MaxDataLength bounds the length of meta and sysex event data.
+	MaxDataLength int
+}
+
+// NewGenerator creates a generator whose output is determined by the seed.
+func NewGenerator(seed int64) *Generator {
+	return &Generator{
+		rng:           rand.New(rand.NewSource(seed)),
+		MaxTracks:     4,
+		MaxEvents:     64,
+		MaxDataLength: 200,
+	}
+}
+
+// maxTimeDelta is the largest delta that fits in four varint bytes, as
+// the standard requires.
+const maxTimeDelta = 0x0FFFFFFF
+
+var smpteFormats = []int{-24, -25, -29, -30}
+
+// File generates a file of any format, with metrical or SMPTE division.
+func (g *Generator) File() *File {
+	hdr := &Header{Format: uint16(g.rng.Intn(3))}
+
+	if g.rng.Intn(4) == 0 {
+		fps := smpteFormats[g.rng.Intn(len(smpteFormats))]
+		hdr.Division = int16(fps<<8 | (1 + g.rng.Intn(120)))
+	} else {
+		hdr.Division = int16(1 + g.rng.Intn(0x7FFF))
+	}
+
+	tracks := 1
+	if hdr.Format != 0 && g.MaxTracks > 1 {
+		tracks = 1 + g.rng.Intn(g.MaxTracks)
+	}
+	hdr.NumberOfTracks = uint16(tracks)
+
+	rv := &File{Header: hdr}
+	for i := 0; i < tracks; i++ {
+		rv.Tracks = append(rv.Tracks, g.Track())
+	}
+	return rv
+}
+
+// Track generates a track of random events, each preceded by a time
+// delta some of the time, and ending with an EndOfTrack meta event.
+func (g *Generator) Track() *Track {
+	rv := &Track{}
+	n := 0
+	if g.MaxEvents > 0 {
+		n = g.rng.Intn(g.MaxEvents)
+	}
+
+	for i := 0; i <= n; i++ {
+		if g.rng.Intn(2) == 0 {
+			rv.Events = append(rv.Events, g.TimeDelta())
+		}
+		if i == n {
+			rv.Events = append(rv.Events, MetaEvent{Type: EndOfTrack})
+		} else {
+			rv.Events = append(rv.Events, g.Event())
+		}
+	}
+	return rv
+}
+
+// TimeDelta generates a nonzero time delta, mostly short ones.
+func (g *Generator) TimeDelta() TimeDeltaEvent {
+	switch g.rng.Intn(8) {
+	case 0:
+		return TimeDeltaEvent(1 + g.rng.Intn(maxTimeDelta))
+	case 1:
+		return TimeDeltaEvent(1 + g.rng.Intn(0x4000))
+	}
+	return TimeDeltaEvent(1 + g.rng.Intn(0x80))
+}
+
+// Event generates a channel message, meta event or sysex event, which is
+// never a time delta.
+func (g *Generator) Event() Event {
+	switch g.rng.Intn(8) {
+	case 0:
+		return g.MetaEvent()
+	case 1:
+		return g.SysexEvent()
+	}
+	return g.MIDIEvent()
+}
+
+// MIDIEvent generates a channel message of any type on any channel.
+func (g *Generator) MIDIEvent() MIDIEvent {
+	status := byte(0x80+g.rng.Intn(7)*0x10) | byte(g.rng.Intn(16))
+	data := make([]byte, midiEventSpecs[int(status>>4)].dataLen)
+	for i := range data {
+		data[i] = byte(g.rng.Intn(0x80))
+	}
+
+	rv, err := NewMIDIEvent(status, data)
+	if err != nil {
+		panic(err)
+	}
+	return rv
+}
+
+// MetaEvent generates a meta event. Well-known types get data of the
+// right shape; others get arbitrary data.
+func (g *Generator) MetaEvent() MetaEvent {
+	switch g.rng.Intn(6) {
+	case 0:
+		return NewTempoEvent(int64(1 + g.rng.Intn(0xFFFFFF)))
+	case 1:
+		return NewTimeSignatureEvent(1+g.rng.Intn(16), 1<<uint(g.rng.Intn(6)))
+	case 2:
+		return NewKeySignatureEvent(g.rng.Intn(15)-7, g.rng.Intn(2) == 1)
+	case 3:
+		text := make([]byte, g.rng.Intn(32))
+		for i := range text {
+			text[i] = byte(' ' + g.rng.Intn(95))
+		}
+		return MetaEvent{Type: byte(1 + g.rng.Intn(7)), Data: g.orNil(text)}
+	}
+
+	return MetaEvent{Type: byte(g.rng.Intn(0x80)), Data: g.orNil(g.data(0x100))}
+}
+
+// SysexEvent generates a complete sysex message starting with F0 and
+// ending with F7, or an F7 escape carrying arbitrary bytes.
+func (g *Generator) SysexEvent() SysexEvent {
+	if g.rng.Intn(4) == 0 {
+		return SysexEvent(append([]byte{0xF7}, g.data(0x100)...))
+	}
+
+	rv := append(SysexEvent{0xF0}, g.data(0x80)...)
+	return append(rv, 0xF7)
+}
+
+// data generates up to MaxDataLength bytes, each below limit.
+func (g *Generator) data(limit int) []byte {
+	n := 0
+	if g.MaxDataLength > 0 {
+		n = g.rng.Intn(g.MaxDataLength + 1)
+	}
+	rv := make([]byte, n)
+	for i := range rv {
+		rv[i] = byte(g.rng.Intn(limit))
+	}
+	return rv
+}
+
+// orNil returns nil for empty data, as Parse does for meta events.
+func (g *Generator) orNil(data []byte) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,299 @@
+// Package score lays out the notes of a track in bars, with the meter,
+// key and tempo found in the file, as a basis for writing notation.
+package score
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/steinarvk/midi"
+)
+
+type Meter struct {
+	Numerator   int
+	Denominator int
+}
+
+type Key struct {
+	Fifths int
+	Minor  bool
+}
+
+// Item is a chord, a single note or a rest (with no keys) within a bar.
+// Notes crossing a bar line are split into items tied together.
+type Item struct {
+	Keys     []int
+	Start    int64
+	Duration int64
+
+	TiedFromPrevious bool
+	TiedToNext       bool
+}
+
+func (i Item) IsRest() bool {
+	return len(i.Keys) == 0
+}
+
+type Bar struct {
+	Start  int64
+	Length int64
+
+	Meter Meter
+	Key   Key
+
+	// MeterChanged and KeyChanged are set when the bar's meter or key
+	// differs from that of the previous bar, and always for the first bar.
+	MeterChanged bool
+	KeyChanged   bool
+
+	Items []Item
+}
+
+type Score struct {
+	Division int64
+	Title    string
+
+	// Tempo is the initial tempo in micros per quarter-note, or zero if
+	// the file does not set one.
+	Tempo int64
+
+	Bars []Bar
+}
+
+type timedMeter struct {
+	tick  int64
+	meter Meter
+}
+
+type timedKey struct {
+	tick int64
+	key  Key
+}
+
+// Layout lays out one track of a file in bars. Time signatures, key
+// signatures and tempo are taken from every track, so that they may come
+// from the conductor track of a format 1 file. Notes struck together are
+// grouped into chords, lasting as long as the shortest of them; notes
+// are cut short when the next note or chord begins.
+func Layout(f *midi.File, trackNo int) (*Score, error) {
+	return layout(f, trackNo, 0)
+}
+
+// LayoutTracks lays out several tracks of a file with the same number of
+// bars, padding each with rests to the end of the bar in which the last
+// of them ends.
+func LayoutTracks(f *midi.File, trackNos []int) ([]*Score, error) {
+	var end int64
+	for _, trackNo := range trackNos {
+		s, err := layout(f, trackNo, 0)
+		if err != nil {
+			return nil, fmt.Errorf("track %d: %v", trackNo, err)
+		}
+		last := s.Bars[len(s.Bars)-1]
+		if barEnd := last.Start + last.Length; barEnd > end {
+			end = barEnd
+		}
+	}
+
+	var rv []*Score
+	for _, trackNo := range trackNos {
+		s, err := layout(f, trackNo, end)
+		if err != nil {
+			return nil, fmt.Errorf("track %d: %v", trackNo, err)
+		}
+		rv = append(rv, s)
+	}
+	return rv, nil
+}
+
+func layout(f *midi.File, trackNo int, minEnd int64) (*Score, error) {
+	if f.Header.Division <= 0 {
+		return nil, fmt.Errorf("SMPTE divisions (%v) are unsupported", f.Header.Division)
+	}
+	if trackNo < 0 || trackNo >= len(f.Tracks) {
+		return nil, fmt.Errorf("no such track: %d (there are %d tracks)", trackNo, len(f.Tracks))
+	}
+
+	rv := &Score{Division: int64(f.Header.Division)}
+	meters, keys, err := signatures(f)
+	if err != nil {
+		return nil, err
+	}
+	tempoTick := int64(-1)
+
+	for i, trk := range f.Tracks {
+		for _, te := range trk.TimedEvents() {
+			meta, ok := te.Event.(midi.MetaEvent)
+			if !ok {
+				continue
+			}
+
+			if tempo, ok := meta.GetTempo(); ok && (tempoTick < 0 || te.Tick < tempoTick) {
+				rv.Tempo = tempo
+				tempoTick = te.Tick
+			}
+			if meta.Type == midi.TrackName && (i == trackNo || (i == 0 && rv.Title == "")) {
+				rv.Title = string(meta.Data)
+			}
+		}
+	}
+
+	items := chords(f.Tracks[trackNo].Notes())
+	if len(items) == 0 {
+		return nil, errors.New("track has no notes")
+	}
+
+	end := items[len(items)-1].Start + items[len(items)-1].Duration
+	if minEnd > end {
+		end = minEnd
+	}
+	rv.Bars = makeBars(rv.Division, meters, keys, end)
+
+	var timeline []Item
+	var now int64
+	for _, item := range items {
+		if item.Start > now {
+			timeline = append(timeline, Item{Start: now, Duration: item.Start - now})
+		}
+		timeline = append(timeline, item)
+		now = item.Start + item.Duration
+	}
+	if end > now {
+		timeline = append(timeline, Item{Start: now, Duration: end - now})
+	}
+
+	b := 0
+	for _, item := range timeline {
+		for item.Duration > 0 {
+			for rv.Bars[b].Start+rv.Bars[b].Length <= item.Start {
+				b++
+			}
+			bar := &rv.Bars[b]
+
+			part := item
+			if barEnd := bar.Start + bar.Length; item.Start+item.Duration > barEnd {
+				part.Duration = barEnd - item.Start
+				part.TiedToNext = !item.IsRest()
+			}
+			bar.Items = append(bar.Items, part)
+
+			item.Start += part.Duration
+			item.Duration -= part.Duration
+			item.TiedFromPrevious = !item.IsRest()
+		}
+	}
+
+	return rv, nil
+}
+
+// Bars divides a file into bars up to the given tick, following the time
+// and key signatures found in any of its tracks. The bars have no items.
+func Bars(f *midi.File, end int64) ([]Bar, error) {
+	if f.Header.Division <= 0 {
+		return nil, fmt.Errorf("SMPTE divisions (%v) are unsupported", f.Header.Division)
+	}
+	meters, keys, err := signatures(f)
+	if err != nil {
+		return nil, err
+	}
+	return makeBars(int64(f.Header.Division), meters, keys, end), nil
+}
+
+// signatures collects the time and key signatures of every track, in
+// order, after the default of 4/4 in C major. Meters whose bars would be
+// shorter than a tick are errors.
+func signatures(f *midi.File) ([]timedMeter, []timedKey, error) {
+	division := int64(f.Header.Division)
+
+	meters := []timedMeter{{0, Meter{4, 4}}}
+	keys := []timedKey{{0, Key{}}}
+
+	for _, trk := range f.Tracks {
+		for _, te := range trk.TimedEvents() {
+			meta, ok := te.Event.(midi.MetaEvent)
+			if !ok {
+				continue
+			}
+			if num, den, ok := meta.GetTimeSignature(); ok && num > 0 {
+				if 4*division*int64(num) < int64(den) {
+					return nil, nil, fmt.Errorf("time signature %d/%d at tick %d: bars shorter than a tick", num, den, te.Tick)
+				}
+				meters = append(meters, timedMeter{te.Tick, Meter{num, den}})
+			}
+			if sharps, minor, ok := meta.GetKeySignature(); ok {
+				keys = append(keys, timedKey{te.Tick, Key{sharps, minor}})
+			}
+		}
+	}
+
+	sort.SliceStable(meters, func(i, j int) bool { return meters[i].tick < meters[j].tick })
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].tick < keys[j].tick })
+	return meters, keys, nil
+}
+
+// chords groups notes struck together and removes overlaps.
+func chords(notes []midi.Note) []Item {
+	var rv []Item
+	for i := 0; i < len(notes); {
+		j := i
+		item := Item{Start: notes[i].Start, Duration: notes[i].Duration}
+		for ; j < len(notes) && notes[j].Start == item.Start; j++ {
+			if notes[j].Duration < item.Duration {
+				item.Duration = notes[j].Duration
+			}
+			if len(item.Keys) == 0 || item.Keys[len(item.Keys)-1] != notes[j].Key {
+				item.Keys = append(item.Keys, notes[j].Key)
+			}
+		}
+		if j < len(notes) && notes[j].Start < item.Start+item.Duration {
+			item.Duration = notes[j].Start - item.Start
+		}
+		if item.Duration > 0 {
+			rv = append(rv, item)
+		}
+		i = j
+	}
+	return rv
+}
+
+func makeBars(division int64, meters []timedMeter, keys []timedKey, end int64) []Bar {
+	var rv []Bar
+	var start int64
+	m, k := 0, 0
+
+	for start < end {
+		for m+1 < len(meters) && meters[m+1].tick <= start {
+			m++
+		}
+		for k+1 < len(keys) && keys[k+1].tick <= start {
+			k++
+		}
+
+		meter := meters[m].meter
+		length := 4 * division * int64(meter.Numerator) / int64(meter.Denominator)
+		if m+1 < len(meters) && meters[m+1].tick < start+length {
+			length = meters[m+1].tick - start
+		}
+
+		bar := Bar{
+			Start:  start,
+			Length: length,
+			Meter:  meter,
+			Key:    keys[k].key,
+		}
+		if len(rv) == 0 {
+			bar.MeterChanged = true
+			bar.KeyChanged = true
+		} else {
+			prev := rv[len(rv)-1]
+			bar.MeterChanged = prev.Meter != bar.Meter
+			bar.KeyChanged = prev.Key != bar.Key
+		}
+
+		rv = append(rv, bar)
+		start += length
+	}
+
+	return rv
+}
@@ -0,0 +1,174 @@
+package score
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/steinarvk/midi"
+)
+
+func TestLayout(t *testing.T) {
+	sw := midi.NewSimpleWriter(4)
+	sw.Event(midi.MetaEvent{Type: midi.TrackName, Data: []byte("Tune")})
+	sw.Event(midi.NewTimeSignatureEvent(3, 4))
+	sw.Event(midi.NewKeySignatureEvent(-1, false))
+	sw.Event(midi.NewTempoEvent(400000))
+	sw.TimeDelta(4)
+	sw.Play([]int{60}, 0x40, 12)
+	sw.Play([]int{64, 67}, 0x40, 4)
+	sw.TimeDelta(4)
+	sw.Play([]int{65}, 0x40, 4)
+
+	s, err := Layout(sw.File(), 0)
+	if err != nil {
+		t.Fatalf("Layout() = err: %v", err)
+	}
+
+	if s.Title != "Tune" || s.Tempo != 400000 || s.Division != 4 {
+		t.Errorf("Layout() = title %q tempo %d division %d", s.Title, s.Tempo, s.Division)
+	}
+
+	want := []Bar{
+		{
+			Start: 0, Length: 12,
+			Meter: Meter{3, 4}, Key: Key{-1, false},
+			MeterChanged: true, KeyChanged: true,
+			Items: []Item{
+				{Start: 0, Duration: 4},
+				{Keys: []int{60}, Start: 4, Duration: 8, TiedToNext: true},
+			},
+		},
+		{
+			Start: 12, Length: 12,
+			Meter: Meter{3, 4}, Key: Key{-1, false},
+			Items: []Item{
+				{Keys: []int{60}, Start: 12, Duration: 4, TiedFromPrevious: true},
+				{Keys: []int{64, 67}, Start: 16, Duration: 4},
+				{Start: 20, Duration: 4},
+			},
+		},
+		{
+			Start: 24, Length: 12,
+			Meter: Meter{3, 4}, Key: Key{-1, false},
+			Items: []Item{
+				{Keys: []int{65}, Start: 24, Duration: 4},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(s.Bars, want) {
+		t.Errorf("Layout().Bars = %+v want %+v", s.Bars, want)
+	}
+}
+
+func TestSpell(t *testing.T) {
+	type spelling struct {
+		letter byte
+		alter  int
+		octave int
+	}
+
+	testcases := []struct {
+		key  Key
+		midi int
+		want spelling
+	}{
+		{Key{0, false}, 60, spelling{'C', 0, 4}},
+		{Key{0, false}, 61, spelling{'C', 1, 4}},
+		{Key{-1, false}, 70, spelling{'B', -1, 4}},
+		{Key{-1, false}, 61, spelling{'D', -1, 4}},
+		{Key{1, false}, 66, spelling{'F', 1, 4}},
+		{Key{1, false}, 65, spelling{'F', 0, 4}},
+		{Key{7, false}, 60, spelling{'B', 1, 3}},
+		{Key{-6, false}, 59, spelling{'C', -1, 4}},
+	}
+
+	for _, testcase := range testcases {
+		letter, alter, octave := testcase.key.Spell(testcase.midi)
+		if got := (spelling{letter, alter, octave}); got != testcase.want {
+			t.Errorf("%+v.Spell(%d) = %c %d %d want %c %d %d", testcase.key, testcase.midi, got.letter, got.alter, got.octave, testcase.want.letter, testcase.want.alter, testcase.want.octave)
+		}
+	}
+
+	for _, testcase := range []struct {
+		key    Key
+		letter byte
+		alter  int
+	}{
+		{Key{0, false}, 'C', 0},
+		{Key{0, true}, 'A', 0},
+		{Key{-3, true}, 'C', 0},
+		{Key{2, false}, 'D', 0},
+		{Key{-2, false}, 'B', -1},
+		{Key{6, true}, 'D', 1},
+	} {
+		if letter, alter := testcase.key.Tonic(); letter != testcase.letter || alter != testcase.alter {
+			t.Errorf("%+v.Tonic() = %c %d want %c %d", testcase.key, letter, alter, testcase.letter, testcase.alter)
+		}
+	}
+}
+
+func TestValues(t *testing.T) {
+	s := &Score{Division: 480}
+
+	testcases := []struct {
+		duration int64
+		want     []Value
+	}{
+		{480, []Value{{4, 0, 480}}},
+		{720, []Value{{4, 1, 720}}},
+		{840, []Value{{4, 2, 840}}},
+		{1440, []Value{{2, 1, 1440}}},
+		{1920, []Value{{1, 0, 1920}}},
+		{2400, []Value{{1, 0, 1920}, {4, 0, 480}}},
+		{1200, []Value{{2, 0, 960}, {8, 0, 240}}},
+		{30, []Value{{64, 0, 30}}},
+	}
+
+	for _, testcase := range testcases {
+		got, err := s.Values(testcase.duration)
+		if err != nil {
+			t.Errorf("Values(%d) = err: %v", testcase.duration, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, testcase.want) {
+			t.Errorf("Values(%d) = %v want %v", testcase.duration, got, testcase.want)
+		}
+	}
+
+	if got, err := s.Values(160); err == nil {
+		t.Errorf("Values(160) = %v want error", got)
+	}
+}
+
+func TestBars(t *testing.T) {
+	sw := midi.NewSimpleWriter(4)
+	sw.EventAt(16, midi.NewTimeSignatureEvent(6, 8))
+
+	got, err := Bars(sw.File(), 40)
+	if err != nil {
+		t.Fatalf("Bars() = err: %v", err)
+	}
+
+	want := []Bar{
+		{Start: 0, Length: 16, Meter: Meter{4, 4}, MeterChanged: true, KeyChanged: true},
+		{Start: 16, Length: 12, Meter: Meter{6, 8}, MeterChanged: true},
+		{Start: 28, Length: 12, Meter: Meter{6, 8}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Bars() = %+v want %+v", got, want)
+	}
+}
+
+func TestBarsShorterThanATick(t *testing.T) {
+	sw := midi.NewSimpleWriter(96)
+	sw.Event(midi.MetaEvent{Type: midi.TimeSignature, Data: []byte{4, 20, 24, 8}})
+	sw.Play([]int{60}, 0x40, 96)
+
+	if got, err := Bars(sw.File(), 96); err == nil {
+		t.Errorf("Bars() = %+v want error", got)
+	}
+	if got, err := Layout(sw.File(), 0); err == nil {
+		t.Errorf("Layout() = %+v want error", got)
+	}
+}
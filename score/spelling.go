@@ -0,0 +1,81 @@
+package score
+
+import "strings"
+
+const (
+	sharpOrder = "FCGDAEB"
+	flatOrder  = "BEADGCF"
+	letters    = "CDEFGAB"
+)
+
+var naturalPitchClasses = map[byte]int{
+	'C': 0,
+	'D': 2,
+	'E': 4,
+	'F': 5,
+	'G': 7,
+	'A': 9,
+	'B': 11,
+}
+
+// Accidental returns the alteration in semitones that a key signature
+// applies to a note letter.
+func (k Key) Accidental(letter byte) int {
+	if k.Fifths > 0 && strings.IndexByte(sharpOrder, letter) < k.Fifths {
+		return 1
+	}
+	if k.Fifths < 0 && strings.IndexByte(flatOrder, letter) < -k.Fifths {
+		return -1
+	}
+	return 0
+}
+
+// Spell names a MIDI key as a letter, an alteration in semitones and an
+// octave number (with middle C in octave 4), as it is spelled in the key:
+// notes of the key's scale are spelled as in the scale, and other notes
+// with sharps in sharp keys and flats in flat keys.
+func (k Key) Spell(key int) (byte, int, int) {
+	pc := ((key % 12) + 12) % 12
+
+	letter, alter := byte(0), 0
+	for i := 0; i < len(letters); i++ {
+		l := letters[i]
+		acc := k.Accidental(l)
+		if (naturalPitchClasses[l]+acc+12)%12 == pc {
+			letter, alter = l, acc
+			break
+		}
+	}
+
+	if letter == 0 {
+		for i := 0; i < len(letters); i++ {
+			l := letters[i]
+			switch naturalPitchClasses[l] {
+			case pc:
+				letter, alter = l, 0
+			case (pc + 11) % 12:
+				if k.Fifths >= 0 && letter == 0 {
+					letter, alter = l, 1
+				}
+			case (pc + 1) % 12:
+				if k.Fifths < 0 && letter == 0 {
+					letter, alter = l, -1
+				}
+			}
+		}
+	}
+
+	natural := key - alter
+	octave := (natural-naturalPitchClasses[letter])/12 - 1
+	return letter, alter, octave
+}
+
+// Tonic returns the letter and alteration of the key's tonic.
+func (k Key) Tonic() (byte, int) {
+	pc := ((k.Fifths*7)%12 + 12) % 12
+	if k.Minor {
+		pc = (pc + 9) % 12
+	}
+	letter, alter, _ := k.Spell(60 + pc)
+	return letter, alter
+}
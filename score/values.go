@@ -0,0 +1,50 @@
+package score
+
+import "fmt"
+
+// Value is a notated note value: Denominator 1 is a whole note, 2 a half
+// note, 4 a quarter note and so on, possibly dotted.
+type Value struct {
+	Denominator int
+	Dots        int
+	Ticks       int64
+}
+
+// Values splits a duration into the fewest notated values it can, longest
+// first, for notes to be written tied together. Values shorter than a
+// 64th note are not used.
+func (s *Score) Values(duration int64) ([]Value, error) {
+	var candidates []Value
+	for den := 1; den <= 64; den *= 2 {
+		if (4*s.Division)%int64(den) != 0 {
+			break
+		}
+		base := 4 * s.Division / int64(den)
+		for dots := 2; dots >= 0; dots-- {
+			if base%(1<<uint(dots)) != 0 || den<<uint(dots) > 64 {
+				continue
+			}
+			ticks := base*2 - base/(1<<uint(dots))
+			candidates = append(candidates, Value{den, dots, ticks})
+		}
+	}
+
+	var rv []Value
+	remaining := duration
+	for remaining > 0 {
+		found := false
+		for _, c := range candidates {
+			if c.Ticks <= remaining {
+				rv = append(rv, c)
+				remaining -= c.Ticks
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("duration %d is not a whole number of 64th notes (division %d)", duration, s.Division)
+		}
+	}
+
+	return rv, nil
+}
@@ -1,40 +1,159 @@
 package midi
 
-import "io"
+import (
+	"io"
+	"sort"
+)
 
+type scheduledEvent struct {
+	tick  int
+	seq   int
+	event Event
+
+	// late marks the release of a note struck at the same tick, which
+	// must follow its own NoteOn.
+	late bool
+}
+
+// schedulingRank orders simultaneous events so that notes are released
+// before anything else happens at the same tick, and struck last.
+func (e scheduledEvent) schedulingRank() int {
+	if e.late {
+		return 3
+	}
+	if m, ok := e.event.(MIDIEvent); ok {
+		switch m.Type {
+		case NoteOff:
+			return 0
+		case NoteOn:
+			return 2
+		}
+	}
+	return 1
+}
+
+// SimpleWriter builds a single-track MIDI file. Events are scheduled at
+// absolute ticks, in any order, and sorted when the file is written.
+// The cursor is the tick at which Play, Note and Event place events.
 type SimpleWriter struct {
 	divisions int16
-	events    []Event
+	cursor    int
+	events    []scheduledEvent
 }
 
 func NewSimpleWriter(divisions int16) *SimpleWriter {
 	return &SimpleWriter{divisions: divisions}
 }
 
+// Play strikes all the keys at the cursor, holds them for duration ticks
+// and advances the cursor past them.
 func (s *SimpleWriter) Play(keys []int, velocity int, duration int) {
 	for _, key := range keys {
-		s.events = append(s.events, MIDIEvent{
-			Type:     NoteOn,
-			Key:      key,
-			Velocity: velocity,
-		})
+		s.NoteAt(s.cursor, key, velocity, duration)
 	}
 	s.TimeDelta(duration)
-	for _, key := range keys {
-		s.events = append(s.events, MIDIEvent{
+}
+
+// Note schedules a note at the cursor without advancing it, so that
+// it may overlap whatever is scheduled next.
+func (s *SimpleWriter) Note(key, velocity, duration int) {
+	s.NoteAt(s.cursor, key, velocity, duration)
+}
+
+// NoteAt schedules a note at an absolute tick. It neither reads nor
+// moves the cursor. A note of zero (or negative) duration is released
+// right after it is struck, at the same tick.
+func (s *SimpleWriter) NoteAt(tick, key, velocity, duration int) {
+	s.EventAt(tick, MIDIEvent{
+		Type:     NoteOn,
+		Key:      key,
+		Velocity: velocity,
+	})
+	if duration > 0 {
+		s.EventAt(tick+duration, MIDIEvent{
 			Type:     NoteOff,
 			Key:      key,
 			Velocity: velocity,
 		})
+		return
+	}
+	s.schedule(tick, MIDIEvent{
+		Type:     NoteOff,
+		Key:      key,
+		Velocity: velocity,
+	}, true)
+}
+
+// Event schedules an arbitrary event at the cursor.
+func (s *SimpleWriter) Event(evt Event) {
+	s.EventAt(s.cursor, evt)
+}
+
+// EventAt schedules an arbitrary event at an absolute tick. Time-delta
+// events are meaningless here and are ignored. Events at negative ticks
+// are played at tick 0, ahead of those scheduled there.
+func (s *SimpleWriter) EventAt(tick int, evt Event) {
+	if _, ok := evt.(TimeDeltaEvent); ok {
+		return
 	}
+	s.schedule(tick, evt, false)
 }
 
+func (s *SimpleWriter) schedule(tick int, evt Event, late bool) {
+	s.events = append(s.events, scheduledEvent{
+		tick:  tick,
+		seq:   len(s.events),
+		event: evt,
+		late:  late,
+	})
+}
+
+// TimeDelta advances the cursor by duration ticks.
 func (s *SimpleWriter) TimeDelta(duration int) {
-	s.events = append(s.events, TimeDeltaEvent(duration))
+	s.cursor += duration
 }
 
-func (s *SimpleWriter) Write(w io.Writer) error {
-	f := File{
+// Cursor returns the current cursor position in ticks.
+func (s *SimpleWriter) Cursor() int {
+	return s.cursor
+}
+
+// Seek moves the cursor to an absolute tick.
+func (s *SimpleWriter) Seek(tick int) {
+	s.cursor = tick
+}
+
+// Events returns the scheduled events in playing order, separated by
+// time-delta events.
+func (s *SimpleWriter) Events() []Event {
+	sorted := make([]scheduledEvent, len(s.events))
+	copy(sorted, s.events)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.tick != b.tick {
+			return a.tick < b.tick
+		}
+		if ra, rb := a.schedulingRank(), b.schedulingRank(); ra != rb {
+			return ra < rb
+		}
+		return a.seq < b.seq
+	})
+
+	var rv []Event
+	var now int
+	for _, se := range sorted {
+		if se.tick > now {
+			rv = append(rv, TimeDeltaEvent(se.tick-now))
+			now = se.tick
+		}
+		rv = append(rv, se.event)
+	}
+	return rv
+}
+
+// File returns the scheduled events as a single-track file.
+func (s *SimpleWriter) File() *File {
+	return &File{
 		Header: &Header{
 			Format:         0,
 			NumberOfTracks: 1,
@@ -42,16 +161,12 @@ func (s *SimpleWriter) Write(w io.Writer) error {
 		},
 		Tracks: []*Track{
 			&Track{
-				Events: s.events,
+				Events: s.Events(),
 			},
 		},
 	}
-	data, err := f.encode()
-	if err != nil {
-		return err
-	}
-	if _, err := w.Write(data); err != nil {
-		return err
-	}
-	return nil
+}
+
+func (s *SimpleWriter) Write(w io.Writer) error {
+	return s.File().Write(w)
 }
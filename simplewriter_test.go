@@ -0,0 +1,74 @@
+package midi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSimpleWriterPlay(t *testing.T) {
+	sw := NewSimpleWriter(96)
+	sw.Play([]int{60}, 0x40, 10)
+	sw.Play([]int{60}, 0x40, 10)
+
+	want := []Event{
+		MIDIEvent{Type: NoteOn, Key: 60, Velocity: 0x40},
+		TimeDeltaEvent(10),
+		MIDIEvent{Type: NoteOff, Key: 60, Velocity: 0x40},
+		MIDIEvent{Type: NoteOn, Key: 60, Velocity: 0x40},
+		TimeDeltaEvent(10),
+		MIDIEvent{Type: NoteOff, Key: 60, Velocity: 0x40},
+	}
+
+	if got := sw.Events(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sw.Events() = %v want %v", got, want)
+	}
+}
+
+func TestSimpleWriterOverlappingNotes(t *testing.T) {
+	sw := NewSimpleWriter(96)
+	// Melody first, then a bass note sustained underneath it.
+	sw.Play([]int{72}, 0x40, 10)
+	sw.Play([]int{74}, 0x40, 10)
+	sw.NoteAt(0, 48, 0x30, 20)
+
+	want := []Event{
+		MIDIEvent{Type: NoteOn, Key: 72, Velocity: 0x40},
+		MIDIEvent{Type: NoteOn, Key: 48, Velocity: 0x30},
+		TimeDeltaEvent(10),
+		MIDIEvent{Type: NoteOff, Key: 72, Velocity: 0x40},
+		MIDIEvent{Type: NoteOn, Key: 74, Velocity: 0x40},
+		TimeDeltaEvent(10),
+		MIDIEvent{Type: NoteOff, Key: 74, Velocity: 0x40},
+		MIDIEvent{Type: NoteOff, Key: 48, Velocity: 0x30},
+	}
+
+	if got := sw.Events(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sw.Events() = %v want %v", got, want)
+	}
+
+	if got := sw.Cursor(); got != 20 {
+		t.Errorf("sw.Cursor() = %d want %d", got, 20)
+	}
+}
+
+func TestSimpleWriterZeroLengthNote(t *testing.T) {
+	sw := NewSimpleWriter(96)
+	sw.NoteAt(10, 60, 0x40, 0)
+	sw.NoteAt(10, 64, 0x40, 10)
+	sw.NoteAt(0, 62, 0x40, 10)
+
+	want := []Event{
+		MIDIEvent{Type: NoteOn, Key: 62, Velocity: 0x40},
+		TimeDeltaEvent(10),
+		MIDIEvent{Type: NoteOff, Key: 62, Velocity: 0x40},
+		MIDIEvent{Type: NoteOn, Key: 60, Velocity: 0x40},
+		MIDIEvent{Type: NoteOn, Key: 64, Velocity: 0x40},
+		MIDIEvent{Type: NoteOff, Key: 60, Velocity: 0x40},
+		TimeDeltaEvent(10),
+		MIDIEvent{Type: NoteOff, Key: 64, Velocity: 0x40},
+	}
+
+	if got := sw.Events(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sw.Events() = %v want %v", got, want)
+	}
+}
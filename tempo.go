@@ -0,0 +1,128 @@
+package midi
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+type tempoChange struct {
+	tick  int64
+	tempo int64
+	at    time.Duration
+}
+
+// TempoMap converts between ticks and time from the start of a file,
+// following its tempo changes.
+type TempoMap struct {
+	division int64
+
+	// smpteTick is the duration of a tick for files with SMPTE divisions,
+	// whose ticks do not depend on the tempo.
+	smpteTick float64
+
+	changes []tempoChange
+}
+
+// NewTempoMap builds the tempo map of a file from the SetTempo events in
+// all of its tracks, starting at the default tempo.
+func NewTempoMap(f *File) (*TempoMap, error) {
+	rv := &TempoMap{division: int64(f.Header.Division)}
+
+	if f.Header.Division < 0 {
+		fps := -int64(int8(f.Header.Division >> 8))
+		ticksPerFrame := int64(f.Header.Division & 0xff)
+		if fps <= 0 || ticksPerFrame == 0 {
+			return nil, fmt.Errorf("invalid SMPTE division (%v)", f.Header.Division)
+		}
+		framesPerSecond := float64(fps)
+		if fps == 29 {
+			framesPerSecond = 30000.0 / 1001
+		}
+		rv.smpteTick = 1e9 / (framesPerSecond * float64(ticksPerFrame))
+		return rv, nil
+	}
+	if f.Header.Division == 0 {
+		return nil, fmt.Errorf("invalid division (%v)", f.Header.Division)
+	}
+
+	var changes []tempoChange
+	for _, track := range f.Tracks {
+		for _, te := range track.TimedEvents() {
+			if meta, ok := te.Event.(MetaEvent); ok {
+				if tempo, ok := meta.GetTempo(); ok && tempo > 0 {
+					changes = append(changes, tempoChange{tick: te.Tick, tempo: tempo})
+				}
+			}
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].tick < changes[j].tick
+	})
+
+	rv.changes = []tempoChange{{tick: 0, tempo: DefaultTempo}}
+	for _, c := range changes {
+		last := &rv.changes[len(rv.changes)-1]
+		if c.tick == last.tick {
+			last.tempo = c.tempo
+			continue
+		}
+		c.at = last.at + rv.span(c.tick-last.tick, last.tempo)
+		rv.changes = append(rv.changes, c)
+	}
+
+	return rv, nil
+}
+
+func (m *TempoMap) span(ticks, tempo int64) time.Duration {
+	return time.Duration(ticks * tempo * 1000 / m.division)
+}
+
+func (m *TempoMap) changeAtTick(tick int64) tempoChange {
+	i := sort.Search(len(m.changes), func(i int) bool { return m.changes[i].tick > tick })
+	return m.changes[i-1]
+}
+
+// Time returns the time at which a tick occurs.
+func (m *TempoMap) Time(tick int64) time.Duration {
+	if m.smpteTick > 0 {
+		return time.Duration(float64(tick) * m.smpteTick)
+	}
+	c := m.changeAtTick(tick)
+	return c.at + m.span(tick-c.tick, c.tempo)
+}
+
+// Tick returns the last tick occurring at or before a time.
+func (m *TempoMap) Tick(t time.Duration) int64 {
+	if m.smpteTick > 0 {
+		return int64(float64(t) / m.smpteTick)
+	}
+	i := sort.Search(len(m.changes), func(i int) bool { return m.changes[i].at > t })
+	c := m.changes[i-1]
+	return c.tick + int64(t-c.at)*m.division/(c.tempo*1000)
+}
+
+// TempoAt returns the tempo in micros per quarter-note at a tick. Files
+// with SMPTE divisions always have the default tempo.
+func (m *TempoMap) TempoAt(tick int64) int64 {
+	if m.smpteTick > 0 {
+		return DefaultTempo
+	}
+	return m.changeAtTick(tick).tempo
+}
+
+// Duration returns the time at which the last event of the file occurs.
+func (f *File) Duration() (time.Duration, error) {
+	m, err := NewTempoMap(f)
+	if err != nil {
+		return 0, err
+	}
+
+	var length int64
+	for _, track := range f.Tracks {
+		if l := track.Length(); l > length {
+			length = l
+		}
+	}
+	return m.Time(length), nil
+}
@@ -0,0 +1,72 @@
+package midi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTempoMap(t *testing.T) {
+	conductor := NewSimpleWriter(480)
+	conductor.EventAt(960, NewTempoEvent(1000000))
+	conductor.EventAt(1920, NewTempoEvent(250000))
+
+	notes := NewSimpleWriter(480)
+	notes.NoteAt(0, 60, 0x40, 2400)
+
+	f := &File{
+		Header: &Header{Format: 1, NumberOfTracks: 2, Division: 480},
+		Tracks: []*Track{conductor.File().Tracks[0], notes.File().Tracks[0]},
+	}
+
+	m, err := NewTempoMap(f)
+	if err != nil {
+		t.Fatalf("NewTempoMap() = err: %v", err)
+	}
+
+	testcases := []struct {
+		tick  int64
+		time  time.Duration
+		tempo int64
+	}{
+		{0, 0, 500000},
+		{480, 500 * time.Millisecond, 500000},
+		{960, time.Second, 1000000},
+		{1440, 2 * time.Second, 1000000},
+		{1920, 3 * time.Second, 250000},
+		{2400, 3250 * time.Millisecond, 250000},
+	}
+
+	for _, testcase := range testcases {
+		if got := m.Time(testcase.tick); got != testcase.time {
+			t.Errorf("Time(%d) = %v want %v", testcase.tick, got, testcase.time)
+		}
+		if got := m.Tick(testcase.time); got != testcase.tick {
+			t.Errorf("Tick(%v) = %d want %d", testcase.time, got, testcase.tick)
+		}
+		if got := m.TempoAt(testcase.tick); got != testcase.tempo {
+			t.Errorf("TempoAt(%d) = %d want %d", testcase.tick, got, testcase.tempo)
+		}
+	}
+
+	if got, err := f.Duration(); err != nil || got != 3250*time.Millisecond {
+		t.Errorf("Duration() = %v, %v want %v", got, err, 3250*time.Millisecond)
+	}
+}
+
+func TestTempoMapSMPTE(t *testing.T) {
+	f := &File{
+		Header: &Header{Format: 0, NumberOfTracks: 1, Division: -(25 << 8) | 40},
+		Tracks: []*Track{&Track{}},
+	}
+
+	m, err := NewTempoMap(f)
+	if err != nil {
+		t.Fatalf("NewTempoMap() = err: %v", err)
+	}
+	if got := m.Time(1000); got != time.Second {
+		t.Errorf("Time(1000) = %v want 1s", got)
+	}
+	if got := m.Tick(time.Second); got != 1000 {
+		t.Errorf("Tick(1s) = %d want 1000", got)
+	}
+}
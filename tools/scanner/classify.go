@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/steinarvk/midi"
+)
+
+// Failure classes.
+const (
+	classHeader        = "header"
+	classChunk         = "chunk"
+	classVarint        = "varint"
+	classRunningStatus = "running status"
+	classMetaLength    = "meta length"
+	classSysex         = "sysex"
+	classTruncated     = "truncated"
+	classTimeout       = "timeout"
+	classRead          = "read"
+	classOther         = "other"
+)
+
+// diagnosis locates the first fault in a file, in the chunk running from
+// chunkStart to chunkEnd (which may lie beyond the end of the file if
+// the file is truncated). The chunk is unknown for header faults.
+type diagnosis struct {
+	class      string
+	offset     int
+	chunkStart int
+	chunkEnd   int
+}
+
+// diagnose walks a file the way the parser reads it, to find out why it
+// fails to parse. It returns false if it finds nothing wrong.
+func diagnose(data []byte) (diagnosis, bool) {
+	if len(data) < 8 || string(data[:4]) != "MThd" {
+		return diagnosis{class: classHeader}, true
+	}
+	headerLength := int(binary.BigEndian.Uint32(data[4:8]))
+	if headerLength < 6 || 8+headerLength > len(data) {
+		return diagnosis{class: classHeader}, true
+	}
+	tracks := int(binary.BigEndian.Uint16(data[10:12]))
+
+	pos := 8 + headerLength
+	sawTrack := false
+	for i := 0; i < tracks && pos+8 <= len(data); i++ {
+		start := pos
+		length := int(binary.BigEndian.Uint32(data[pos+4 : pos+8]))
+		end := pos + 8 + length
+		if string(data[pos:pos+4]) != "MTrk" {
+			pos = end
+			continue
+		}
+		sawTrack = true
+
+		bodyEnd := end
+		if bodyEnd > len(data) {
+			bodyEnd = len(data)
+		}
+		if class, offset, ok := diagnoseTrack(data[:bodyEnd], pos+8, nil); ok {
+			if offset >= len(data) && end > len(data) {
+				class = classTruncated
+			}
+			return diagnosis{class: class, offset: offset, chunkStart: start, chunkEnd: end}, true
+		}
+		if end > len(data) {
+			// The parser stops at the end of the file, as the track
+			// ended between events.
+			break
+		}
+		pos = end
+	}
+
+	if !sawTrack {
+		return diagnosis{class: classChunk, offset: 8 + headerLength}, true
+	}
+	return diagnosis{}, false
+}
+
+// diagnoseTrack walks the events of a track body, which runs to the end
+// of data, calling onEvent with the offset at which each event begins.
+// Like the parser, it takes any status byte other than those of meta and
+// sysex events to set the running status.
+func diagnoseTrack(data []byte, pos int, onEvent func(int)) (string, int, bool) {
+	var runningStatus byte
+	partial := 0
+
+	for pos < len(data) {
+		if onEvent != nil {
+			onEvent(pos)
+		}
+
+		// Time delta. The parser takes running out of bytes while the
+		// value is still zero for the end of the track.
+		delta := 0
+		for {
+			if pos >= len(data) {
+				if delta == 0 {
+					return "", 0, false
+				}
+				return classVarint, pos, true
+			}
+			b := data[pos]
+			pos++
+			delta |= int(b & 0x7F)
+			if b&0x80 == 0 {
+				break
+			}
+		}
+
+		for done := false; !done; {
+			if pos >= len(data) {
+				return classChunk, pos, true
+			}
+			b := data[pos]
+			pos++
+
+			switch {
+			case b == 0xFF, b == 0xF0, b == 0xF7:
+				class := classSysex
+				if b == 0xFF {
+					class = classMetaLength
+					partial = 0
+					pos++
+				}
+				length := 0
+				for {
+					if pos >= len(data) {
+						return class, pos, true
+					}
+					b := data[pos]
+					pos++
+					length = length<<7 | int(b&0x7F)
+					if length == 0 || b&0x80 == 0 {
+						break
+					}
+				}
+				if pos+length > len(data) {
+					return class, len(data), true
+				}
+				pos += length
+				done = true
+
+			case b&0x80 != 0:
+				if partial > 0 {
+					return classRunningStatus, pos - 1, true
+				}
+				runningStatus = b
+
+			default:
+				dataLen := 2
+				switch runningStatus & 0xF0 {
+				case 0x80, 0x90, 0xA0, 0xB0, 0xE0:
+				case 0xC0, 0xD0:
+					dataLen = 1
+				default:
+					return classRunningStatus, pos - 1, true
+				}
+				partial++
+				if partial >= dataLen {
+					partial = 0
+					done = true
+				}
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// classify finds the class of a parse failure, falling back on the
+// error message when diagnose finds nothing wrong.
+func classify(data []byte, err error) string {
+	if d, ok := diagnose(data); ok {
+		return d.class
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "parsing header"):
+		return classHeader
+	case strings.Contains(msg, "varint"):
+		return classVarint
+	case strings.Contains(msg, "running status"):
+		return classRunningStatus
+	case strings.Contains(msg, "sysex"):
+		return classSysex
+	case strings.Contains(msg, "EOF"):
+		return classTruncated
+	}
+	return classOther
+}
+
+// maxMinimizeAttempts bounds the number of times minimize parses.
+const maxMinimizeAttempts = 5000
+
+// minimize shrinks a file that fails to parse to a small byte sequence
+// that fails the same way. It keeps only the header and the chunk in
+// which the failure lies, and then removes as many events and then bytes
+// from the chunk as it can, keeping the chunk length consistent with
+// what remains.
+func minimize(data []byte, class string) []byte {
+	attempts := 0
+	fails := func(candidate []byte) bool {
+		if attempts >= maxMinimizeAttempts {
+			return false
+		}
+		attempts++
+		if _, err := midi.Parse(bytes.NewReader(candidate)); err == nil {
+			return false
+		}
+		d, ok := diagnose(candidate)
+		return ok && d.class == class
+	}
+
+	d, ok := diagnose(data)
+	if !ok || d.class != class {
+		return data
+	}
+
+	if class == classHeader {
+		if len(data) > 14 && fails(data[:14]) {
+			return data[:14]
+		}
+		return data
+	}
+
+	headerEnd := 8 + int(binary.BigEndian.Uint32(data[4:8]))
+	header := append([]byte(nil), data[:headerEnd]...)
+	binary.BigEndian.PutUint16(header[10:12], 1)
+
+	if d.chunkEnd == 0 {
+		// No track was found: one empty chunk of the first kind will do.
+		if d.offset+4 <= len(data) {
+			candidate := append(header, data[d.offset:d.offset+4]...)
+			candidate = append(candidate, 0, 0, 0, 0)
+			if fails(candidate) {
+				return candidate
+			}
+		}
+		return data
+	}
+
+	bodyEnd := d.chunkEnd
+	if bodyEnd > len(data) {
+		bodyEnd = len(data)
+	}
+	body := data[d.chunkStart+8 : bodyEnd]
+	overrun := d.chunkEnd - bodyEnd
+
+	build := func(body []byte) []byte {
+		rv := append([]byte(nil), header...)
+		rv = append(rv, "MTrk"...)
+		var length [4]byte
+		binary.BigEndian.PutUint32(length[:], uint32(len(body)+overrun))
+		rv = append(rv, length[:]...)
+		return append(rv, body...)
+	}
+
+	if !fails(build(body)) {
+		return data
+	}
+
+	var starts []int
+	diagnoseTrack(body, 0, func(pos int) { starts = append(starts, pos) })
+	body = join(shrink(split(body, starts), build, fails))
+	body = join(shrink(split(body, nil), build, fails))
+	return build(body)
+}
+
+// split cuts data at the given offsets, or into single bytes if there
+// are none.
+func split(data []byte, offsets []int) [][]byte {
+	var rv [][]byte
+	if offsets == nil {
+		for i := range data {
+			rv = append(rv, data[i:i+1])
+		}
+		return rv
+	}
+
+	prev := 0
+	for _, offset := range offsets {
+		if offset > prev {
+			rv = append(rv, data[prev:offset])
+			prev = offset
+		}
+	}
+	return append(rv, data[prev:])
+}
+
+func join(units [][]byte) []byte {
+	var rv []byte
+	for _, unit := range units {
+		rv = append(rv, unit...)
+	}
+	return rv
+}
+
+// shrink removes ever smaller runs of units for as long as the file
+// built from what remains still fails.
+func shrink(units [][]byte, build func([]byte) []byte, fails func([]byte) bool) [][]byte {
+	for size := len(units) / 2; size >= 1; size /= 2 {
+		for i := 0; i+size <= len(units); {
+			candidate := append(append([][]byte(nil), units[:i]...), units[i+size:]...)
+			if fails(build(join(candidate))) {
+				units = candidate
+			} else {
+				i++
+			}
+		}
+	}
+	return units
+}
+
+// writeReproducer writes a minimized version of a failing file to dir,
+// named after its class of failure.
+func writeReproducer(dir, class string, data []byte) error {
+	name := strings.Replace(class, " ", "_", -1) + ".mid"
+	return ioutil.WriteFile(filepath.Join(dir, name), minimize(data, class), 0644)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/steinarvk/midi"
+)
+
+func smf(body []byte, declared int) []byte {
+	rv := []byte("MThd\x00\x00\x00\x06\x00\x00\x00\x01\x00\x60MTrk")
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(declared))
+	rv = append(rv, length[:]...)
+	return append(rv, body...)
+}
+
+func TestDiagnose(t *testing.T) {
+	notes := []byte{0x00, 0x90, 0x3c, 0x40, 0x60, 0x80, 0x3c, 0x40}
+	eot := []byte{0x00, 0xff, 0x2f, 0x00}
+	track := func(events ...[]byte) []byte {
+		var rv []byte
+		rv = append(rv, notes...)
+		for _, evt := range events {
+			rv = append(rv, evt...)
+		}
+		return append(rv, eot...)
+	}
+	good := track()
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"ok", smf(good, len(good)), ""},
+		{"header", append([]byte("MThx"), smf(good, len(good))[4:]...), classHeader},
+		{"chunk", bytes.Replace(smf(good, len(good)), []byte("MTrk"), []byte("XTrk"), 1), classChunk},
+		{"varint", smf(append(track(), 0x81), len(good)+1), classVarint},
+		{"running status", smf(append([]byte{0x00, 0x3c, 0x40}, good...), len(good)+3), classRunningStatus},
+		{"system common", smf(track([]byte{0x00, 0xf2, 0x01, 0x01}), len(good)+4), classRunningStatus},
+		{"meta length", smf(append(track(), 0x00, 0xff, 0x01, 0x7f, 'a'), len(good)+5), classMetaLength},
+		{"sysex", smf(append(track(), 0x00, 0xf0, 0x7f, 'a'), len(good)+4), classSysex},
+		{"cut by chunk", smf(good, len(notes)-1), classChunk},
+		{"truncated", smf(good[:len(notes)-1], len(good)), classTruncated},
+	} {
+		d, bad := diagnose(tc.data)
+		if bad != (tc.want != "") || d.class != tc.want {
+			t.Errorf("%s: diagnose(% x) = %q want %q", tc.name, tc.data, d.class, tc.want)
+		}
+
+		_, err := midi.Parse(bytes.NewReader(tc.data))
+		if (err != nil) != bad {
+			t.Errorf("%s: Parse(% x) = %v but diagnose found fault: %v", tc.name, tc.data, err, bad)
+		}
+		if !bad {
+			continue
+		}
+
+		min := minimize(tc.data, tc.want)
+		if len(min) > len(tc.data) {
+			t.Errorf("%s: minimize(% x) = % x, which is longer", tc.name, tc.data, min)
+		}
+		if _, err := midi.Parse(bytes.NewReader(min)); err == nil {
+			t.Errorf("%s: minimize(% x) = % x, which parses", tc.name, tc.data, min)
+		}
+		if d, _ := diagnose(min); d.class != tc.want {
+			t.Errorf("%s: minimize(% x) = % x, of class %q want %q", tc.name, tc.data, min, d.class, tc.want)
+		}
+	}
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"flag"
-	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/steinarvk/midi"
@@ -15,11 +18,44 @@ import (
 var (
 	scanPath     = flag.String("path", "", "MIDI file scan path (dir or file)")
 	logSuccesses = flag.Bool("log_success", false, "log individual parsing successes")
-	showFiles    = flag.Bool("show_files", false, "log contents of tracks")
+	showFiles    = flag.Bool("show_files", false, "print contents of tracks in text form")
+	absolute     = flag.Bool("absolute_ticks", false, "print absolute rather than delta ticks with --show_files")
 	showHeader   = flag.Bool("show_headers", false, "log headers of files")
 	verbose      = flag.Bool("verbose", false, "very detailed logging")
+	fileStatsOut = flag.String("file_stats", "", "write per-file statistics to this path (- for stdout)")
+	corpusOut    = flag.String("corpus_stats", "", "write corpus-wide statistics to this path (- for stdout)")
+	statsFormat  = flag.String("stats_format", "csv", "format of --file_stats and --corpus_stats: csv or json")
+	workers      = flag.Int("j", runtime.NumCPU(), "number of files to parse concurrently")
+	timeout      = flag.Duration("timeout", 0, "give up waiting for a file to parse after this long (0 for no limit); the parse itself runs on in the background")
+
+	reproducersDir = flag.String("reproducers_dir", "", "write a minimized failing file for each class of failure to this directory")
+	maxExamples    = flag.Int("failure_examples", 3, "number of example paths to list for each class of failure")
 )
 
+// failureClass counts the failures of one class.
+type failureClass struct {
+	name     string
+	count    int64
+	examples []string
+}
+
+// writeOutput writes to a file, or to stdout if the path is "-".
+func writeOutput(path string, write func(io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 func main() {
 	flag.Parse()
 
@@ -27,74 +63,178 @@ func main() {
 		log.Fatalf("missing required argument: --path")
 	}
 
+	if *statsFormat != "csv" && *statsFormat != "json" {
+		log.Fatalf("invalid --stats_format %q: must be csv or json", *statsFormat)
+	}
+
 	if *verbose {
 		midi.VeryDetailedLogging = true
 	}
 
-	var successes, failures int64
+	if *workers < 1 {
+		log.Fatalf("invalid -j %d: must be at least 1", *workers)
+	}
+
+	var successes, failures, timeouts int64
 	var successSize, totalSize int64
 
-	onEachDir := func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	var allStats []fileStats
+	corpus := newCorpusStats()
+
+	var classes []*failureClass
+	classIndex := map[string]*failureClass{}
+
+	if *reproducersDir != "" {
+		if err := os.MkdirAll(*reproducersDir, 0755); err != nil {
+			log.Fatalf("creating --reproducers_dir: %v", err)
 		}
+	}
 
-		if !strings.HasSuffix(strings.ToLower(path), ".mid") {
+	t0 := time.Now()
+	jobs := make(chan job)
+	results := make(chan *result)
+
+	var walkErr error
+	go func() {
+		defer close(jobs)
+		index := 0
+		walkErr = filepath.Walk(*scanPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || !strings.HasSuffix(strings.ToLower(path), ".mid") {
+				return nil
+			}
+			jobs <- job{index: index, path: path, size: info.Size()}
+			index++
 			return nil
+		})
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results <- process(j)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Results are reported, and counted, in the order the files were
+	// found, whatever order they finish in.
+	report := func(r *result) {
+		for _, line := range r.logs {
+			log.Print(line)
 		}
+		os.Stdout.Write(r.stdout.Bytes())
 
-		f, err := os.Open(path)
-		if err != nil {
-			return fmt.Errorf("error opening %q: %v", path, err)
+		totalSize += r.size
+		switch {
+		case r.ok:
+			successes++
+			successSize += r.size
+		case r.timedOut:
+			timeouts++
+			failures++
+		default:
+			failures++
 		}
-		defer f.Close()
 
-		totalSize += info.Size()
+		if !r.ok {
+			fc, ok := classIndex[r.class]
+			if !ok {
+				fc = &failureClass{name: r.class}
+				classIndex[r.class] = fc
+				classes = append(classes, fc)
 
-		data, err := midi.Parse(f)
-		if err != nil {
-			log.Printf("parsing %q: error: %v", path, err)
-			failures++
-		} else {
-			if *logSuccesses {
-				log.Printf("parsing %q: ok: %v", path, data)
-			}
-			if *showHeader {
-				log.Printf("file %q header: format=%d tracks=%d division=%d", path, data.Header.Format, data.Header.NumberOfTracks, data.Header.Division)
-			}
-			if *showFiles {
-				log.Printf("showing file %q", path)
-				for i, track := range data.Tracks {
-					for j, event := range track.Events {
-						log.Printf("trk % 2d evt % 8d %v", i, j, event)
+				if r.contents != nil {
+					if err := writeReproducer(*reproducersDir, r.class, r.contents); err != nil {
+						log.Fatalf("writing reproducer for %q: %v", r.path, err)
 					}
 				}
 			}
-			successes++
-			successSize += info.Size()
+			fc.count++
+			if len(fc.examples) < *maxExamples {
+				fc.examples = append(fc.examples, r.path)
+			}
 		}
 
-		return nil
+		if r.stats != nil {
+			corpus.add(*r.stats)
+			if *fileStatsOut != "" {
+				allStats = append(allStats, *r.stats)
+			}
+		}
 	}
 
-	t0 := time.Now()
-	if err := filepath.Walk(*scanPath, onEachDir); err != nil {
-		log.Fatalf("scanning failed: %v", err)
+	pending := map[int]*result{}
+	next := 0
+	for r := range results {
+		pending[r.index] = r
+		for pending[next] != nil {
+			report(pending[next])
+			delete(pending, next)
+			next++
+		}
+	}
+	if walkErr != nil {
+		log.Fatalf("scanning failed: %v", walkErr)
 	}
 	t1 := time.Now()
 
+	corpus.Failures = failures
+	if *fileStatsOut != "" {
+		err := writeOutput(*fileStatsOut, func(w io.Writer) error {
+			return writeFileStats(w, *statsFormat, allStats)
+		})
+		if err != nil {
+			log.Fatalf("writing file statistics: %v", err)
+		}
+	}
+	if *corpusOut != "" {
+		err := writeOutput(*corpusOut, func(w io.Writer) error {
+			return writeCorpusStats(w, *statsFormat, corpus)
+		})
+		if err != nil {
+			log.Fatalf("writing corpus statistics: %v", err)
+		}
+	}
+
 	secs := t1.Sub(t0).Seconds()
 
 	ok := (successes == failures) && successes > 0
-	pct := 100 * float64(successes) / float64(successes+failures)
+	var pct float64
+	if successes+failures > 0 {
+		pct = 100 * float64(successes) / float64(successes+failures)
+	}
 
 	log.Printf("%d/%d file(s) parsed successfully", successes, failures+successes)
+	if timeouts > 0 {
+		log.Printf("%d file(s) timed out", timeouts)
+	}
 	log.Printf("%d files of a total of %d bytes parsed successfully", successes, successSize)
 	log.Printf("Success rate: %.2f%%", pct)
 	log.Printf("Time taken: %v", t1.Sub(t0))
 	log.Printf("Successful bytes parsed per second: %v", float64(successSize)/secs)
 	log.Printf("Total bytes parsed per second: %v", float64(totalSize)/secs)
 
+	if len(classes) > 0 {
+		sort.SliceStable(classes, func(i, j int) bool { return classes[i].count > classes[j].count })
+		log.Printf("Failures by class:")
+		for _, fc := range classes {
+			log.Printf("  %s: %d", fc.name, fc.count)
+			for _, path := range fc.examples {
+				log.Printf("    %s", path)
+			}
+		}
+	}
+
 	if ok {
 		log.Fatalf("failures encountered")
 	}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/steinarvk/midi"
+	"github.com/steinarvk/midi/miditext"
+)
+
+type job struct {
+	index int
+	path  string
+	size  int64
+}
+
+// result is everything a worker found out about a file, including the
+// output to print for it, so that results can be reported in order.
+type result struct {
+	job
+
+	ok       bool
+	timedOut bool
+	logs     []string
+	stdout   bytes.Buffer
+	stats    *fileStats
+
+	// class is the kind of failure, and contents the file itself if a
+	// reproducer may be needed.
+	class    string
+	contents []byte
+}
+
+func (r *result) logf(format string, args ...interface{}) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+// deadlineReader fails once its context is done, so that a parse that
+// has been given up on stops at its next read.
+type deadlineReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (d *deadlineReader) Read(buf []byte) (int, error) {
+	if err := d.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return d.r.Read(buf)
+}
+
+type parsed struct {
+	file *midi.File
+	err  error
+}
+
+func process(j job) *result {
+	rv := &result{job: j}
+
+	contents, err := ioutil.ReadFile(j.path)
+	if err != nil {
+		rv.class = classRead
+		rv.logf("reading %q: error: %v", j.path, err)
+		return rv
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	// Parse stops only when a read fails, so a parse that is slow between
+	// reads carries on after the timeout, and its goroutine is leaked until
+	// it finishes. Many slow files can pile up goroutines this way; the
+	// timeout bounds the wait for a result, not the work done.
+	done := make(chan parsed, 1)
+	go func() {
+		f, err := midi.Parse(&deadlineReader{ctx, bytes.NewReader(contents)})
+		done <- parsed{f, err}
+	}()
+
+	var p parsed
+	select {
+	case p = <-done:
+	case <-ctx.Done():
+		rv.timedOut = true
+		rv.class = classTimeout
+		rv.logf("parsing %q: timed out after %v", j.path, *timeout)
+		return rv
+	}
+
+	if p.err != nil {
+		rv.class = classify(contents, p.err)
+		if *reproducersDir != "" {
+			rv.contents = contents
+		}
+		rv.logf("parsing %q: error: %v", j.path, p.err)
+		return rv
+	}
+
+	data := p.file
+	rv.ok = true
+	if *logSuccesses {
+		rv.logf("parsing %q: ok: %v", j.path, data)
+	}
+	if *showHeader {
+		rv.logf("file %q header: format=%d tracks=%d division=%d", j.path, data.Header.Format, data.Header.NumberOfTracks, data.Header.Division)
+	}
+	if *showFiles {
+		rv.logf("showing file %q", j.path)
+		fmt.Fprintf(&rv.stdout, "# %s\n", j.path)
+		if err := miditext.Write(&rv.stdout, data, miditext.Options{AbsoluteTicks: *absolute}); err != nil {
+			rv.logf("showing %q: error: %v", j.path, err)
+		}
+	}
+	if *fileStatsOut != "" || *corpusOut != "" {
+		stats := collectStats(j.path, j.size, data)
+		rv.stats = &stats
+	}
+	return rv
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/steinarvk/midi"
+)
+
+const drumChannel = 9
+
+// fileStats describes one successfully parsed file. LowestKey and
+// HighestKey are -1 for files without notes.
+type fileStats struct {
+	Path       string         `json:"path"`
+	Size       int64          `json:"size"`
+	Format     uint16         `json:"format"`
+	Tracks     int            `json:"tracks"`
+	Division   int16          `json:"division"`
+	Channels   int            `json:"channels"`
+	Notes      int            `json:"notes"`
+	Seconds    float64        `json:"seconds"`
+	Programs   []int          `json:"programs"`
+	MetaEvents map[string]int `json:"metaEvents"`
+	LowestKey  int            `json:"lowestKey"`
+	HighestKey int            `json:"highestKey"`
+}
+
+func metaName(metaType byte) string {
+	if name, ok := midi.MetaEventName(metaType); ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02X", metaType)
+}
+
+func collectStats(path string, size int64, f *midi.File) fileStats {
+	rv := fileStats{
+		Path:       path,
+		Size:       size,
+		Format:     f.Header.Format,
+		Tracks:     len(f.Tracks),
+		Division:   f.Header.Division,
+		MetaEvents: map[string]int{},
+		LowestKey:  -1,
+		HighestKey: -1,
+	}
+
+	if d, err := f.Duration(); err == nil {
+		rv.Seconds = d.Seconds()
+	}
+
+	channels := map[int]bool{}
+	programs := map[int]bool{}
+	for _, track := range f.Tracks {
+		for _, evt := range track.Events {
+			switch v := evt.(type) {
+			case midi.MetaEvent:
+				rv.MetaEvents[metaName(v.Type)]++
+			case midi.MIDIEvent:
+				channels[v.Channel] = true
+				if v.Type == midi.ProgramChange && v.Channel != drumChannel {
+					programs[v.ProgramNumber] = true
+				}
+			}
+		}
+
+		for _, n := range track.Notes() {
+			rv.Notes++
+			if rv.LowestKey < 0 || n.Key < rv.LowestKey {
+				rv.LowestKey = n.Key
+			}
+			if n.Key > rv.HighestKey {
+				rv.HighestKey = n.Key
+			}
+		}
+	}
+
+	rv.Channels = len(channels)
+	for program := range programs {
+		rv.Programs = append(rv.Programs, program)
+	}
+	sort.Ints(rv.Programs)
+
+	return rv
+}
+
+// corpusStats aggregates the statistics of every file scanned. The
+// histograms count files, except MetaEvents which counts events.
+type corpusStats struct {
+	Files      int64          `json:"files"`
+	Failures   int64          `json:"failures"`
+	Formats    map[string]int `json:"formats"`
+	Divisions  map[string]int `json:"divisions"`
+	Tracks     map[string]int `json:"tracks"`
+	Channels   map[string]int `json:"channels"`
+	Programs   map[string]int `json:"programs"`
+	MetaEvents map[string]int `json:"metaEvents"`
+	Notes      int64          `json:"notes"`
+	Seconds    float64        `json:"seconds"`
+	LowestKey  int            `json:"lowestKey"`
+	HighestKey int            `json:"highestKey"`
+}
+
+func newCorpusStats() *corpusStats {
+	return &corpusStats{
+		Formats:    map[string]int{},
+		Divisions:  map[string]int{},
+		Tracks:     map[string]int{},
+		Channels:   map[string]int{},
+		Programs:   map[string]int{},
+		MetaEvents: map[string]int{},
+		LowestKey:  -1,
+		HighestKey: -1,
+	}
+}
+
+func (c *corpusStats) add(s fileStats) {
+	c.Files++
+	c.Formats[strconv.Itoa(int(s.Format))]++
+	c.Divisions[strconv.Itoa(int(s.Division))]++
+	c.Tracks[strconv.Itoa(s.Tracks)]++
+	c.Channels[strconv.Itoa(s.Channels)]++
+	for _, program := range s.Programs {
+		c.Programs[strconv.Itoa(program)]++
+	}
+	for name, count := range s.MetaEvents {
+		c.MetaEvents[name] += count
+	}
+	c.Notes += int64(s.Notes)
+	c.Seconds += s.Seconds
+	if s.LowestKey >= 0 && (c.LowestKey < 0 || s.LowestKey < c.LowestKey) {
+		c.LowestKey = s.LowestKey
+	}
+	if s.HighestKey > c.HighestKey {
+		c.HighestKey = s.HighestKey
+	}
+}
+
+func writeFileStats(w io.Writer, format string, stats []fileStats) error {
+	if format == "json" {
+		if stats == nil {
+			stats = []fileStats{}
+		}
+		return writeJSON(w, stats)
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"path", "size", "format", "tracks", "division", "channels", "notes", "seconds", "programs", "lowest_key", "highest_key"})
+	for _, s := range stats {
+		var programs []string
+		for _, program := range s.Programs {
+			programs = append(programs, strconv.Itoa(program))
+		}
+		cw.Write([]string{
+			s.Path,
+			strconv.FormatInt(s.Size, 10),
+			strconv.Itoa(int(s.Format)),
+			strconv.Itoa(s.Tracks),
+			strconv.Itoa(int(s.Division)),
+			strconv.Itoa(s.Channels),
+			strconv.Itoa(s.Notes),
+			strconv.FormatFloat(s.Seconds, 'f', 3, 64),
+			strings.Join(programs, " "),
+			strconv.Itoa(s.LowestKey),
+			strconv.Itoa(s.HighestKey),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeCorpusStats writes CSV as rows of statistic, value and count, with
+// an empty value for totals.
+func writeCorpusStats(w io.Writer, format string, c *corpusStats) error {
+	if format == "json" {
+		return writeJSON(w, c)
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"statistic", "value", "count"})
+	totals := [][]string{
+		{"files", "", strconv.FormatInt(c.Files, 10)},
+		{"failures", "", strconv.FormatInt(c.Failures, 10)},
+		{"notes", "", strconv.FormatInt(c.Notes, 10)},
+		{"seconds", "", strconv.FormatFloat(c.Seconds, 'f', 3, 64)},
+		{"lowest_key", "", strconv.Itoa(c.LowestKey)},
+		{"highest_key", "", strconv.Itoa(c.HighestKey)},
+	}
+	for _, row := range totals {
+		cw.Write(row)
+	}
+
+	histograms := []struct {
+		name   string
+		counts map[string]int
+	}{
+		{"format", c.Formats},
+		{"division", c.Divisions},
+		{"tracks", c.Tracks},
+		{"channels", c.Channels},
+		{"program", c.Programs},
+		{"meta_event", c.MetaEvents},
+	}
+	for _, h := range histograms {
+		var values []string
+		for value := range h.counts {
+			values = append(values, value)
+		}
+		sort.Slice(values, func(i, j int) bool {
+			a, errA := strconv.Atoi(values[i])
+			b, errB := strconv.Atoi(values[j])
+			if errA == nil && errB == nil {
+				return a < b
+			}
+			return values[i] < values[j]
+		})
+		for _, value := range values {
+			cw.Write([]string{h.name, value, strconv.Itoa(h.counts[value])})
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
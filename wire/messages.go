@@ -0,0 +1,90 @@
+// Package wire reads and writes MIDI as it is sent over a cable, a serial
+// port or a raw device, rather than as it is stored in a file.
+//
+// On the wire there are no time deltas and no meta events: 0xFF is the
+// System Reset message, sysex messages run from F0 to F7 without a
+// length, realtime messages (F8 to FF) may appear anywhere, even in the
+// middle of another message, and channel messages may use running status.
+package wire
+
+import (
+	"fmt"
+)
+
+// Message is a message read from the wire: a midi.MIDIEvent for channel
+// messages, or one of the system message types of this package.
+type Message interface{}
+
+// Sysex is a system exclusive message, holding the bytes between F0 and
+// the F7 (or other status byte) that ends it.
+type Sysex []byte
+
+// Realtime is a single-byte system realtime message.
+type Realtime byte
+
+const (
+	TimingClock   Realtime = 0xF8
+	Start         Realtime = 0xFA
+	Continue      Realtime = 0xFB
+	Stop          Realtime = 0xFC
+	ActiveSensing Realtime = 0xFE
+	SystemReset   Realtime = 0xFF
+)
+
+// QuarterFrame is an MTC quarter frame message (F1), holding the piece
+// number in its high nibble and the value in its low nibble.
+type QuarterFrame byte
+
+// SongPosition is a Song Position Pointer message (F2), counting MIDI
+// beats (sixteenth notes, or six timing clocks) since the song's start.
+type SongPosition int
+
+// SongSelect is a Song Select message (F3).
+type SongSelect int
+
+// TuneRequest is a Tune Request message (F6).
+type TuneRequest struct{}
+
+// Undefined is a system common message with an undefined status byte
+// (F4 or F5), which carries no data.
+type Undefined byte
+
+var realtimeNames = map[Realtime]string{
+	TimingClock:   "TimingClock",
+	Start:         "Start",
+	Continue:      "Continue",
+	Stop:          "Stop",
+	ActiveSensing: "ActiveSensing",
+	SystemReset:   "SystemReset",
+}
+
+func (m Realtime) String() string {
+	if name, ok := realtimeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("Realtime:%02x", byte(m))
+}
+
+func (m Sysex) String() string {
+	return fmt.Sprintf("SysEx % 02x", []byte(m))
+}
+
+func (m QuarterFrame) String() string {
+	return fmt.Sprintf("QuarterFrame piece=%d value=%d", byte(m)>>4, byte(m)&0x0F)
+}
+
+func (m SongPosition) String() string {
+	return fmt.Sprintf("SongPosition %d", int(m))
+}
+
+func (m SongSelect) String() string {
+	return fmt.Sprintf("SongSelect %d", int(m))
+}
+
+func (m TuneRequest) String() string {
+	return "TuneRequest"
+}
+
+func (m Undefined) String() string {
+	return fmt.Sprintf("Undefined:%02x", byte(m))
+}
@@ -0,0 +1,170 @@
+package wire
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/steinarvk/midi"
+)
+
+// Reader reads messages from a stream of wire MIDI.
+//
+// Following the MIDI specification, data bytes without a status byte to
+// give them meaning are ignored, as is an F7 outside sysex, and a message
+// cut short by a status byte is dropped. A sysex message cut short by a
+// status byte other than a realtime one is delivered as if it had ended
+// with F7. System Reset drops whatever message was in progress and the
+// running status.
+type Reader struct {
+	r io.ByteReader
+
+	// status is the status byte of the message in progress, which for
+	// channel messages stays as the running status once they are done.
+	status byte
+	data   []byte
+
+	inSysex bool
+	sysex   []byte
+
+	pending []Message
+}
+
+// NewReader creates a Reader. Reads from r are buffered unless it is an
+// io.ByteReader, but a Reader never waits for more bytes than are needed
+// to complete a message.
+func NewReader(r io.Reader) *Reader {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Reader{r: br}
+}
+
+// dataLength is the number of data bytes following a status byte.
+func dataLength(status byte) int {
+	switch {
+	case status >= 0x80 && status < 0xF0:
+		switch status & 0xF0 {
+		case 0xC0, 0xD0:
+			return 1
+		}
+		return 2
+	case status == 0xF1, status == 0xF3:
+		return 1
+	case status == 0xF2:
+		return 2
+	}
+	return 0
+}
+
+// Read returns the next message. At the end of the stream it returns
+// io.EOF, or io.ErrUnexpectedEOF if a message was in progress.
+func (r *Reader) Read() (Message, error) {
+	for len(r.pending) == 0 {
+		b, err := r.r.ReadByte()
+		if err == io.EOF && (r.inSysex || len(r.data) > 0) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := r.feed(b); err != nil {
+			return nil, err
+		}
+	}
+
+	rv := r.pending[0]
+	r.pending = r.pending[1:]
+	return rv, nil
+}
+
+func (r *Reader) emit(m Message) {
+	r.pending = append(r.pending, m)
+}
+
+func (r *Reader) endSysex() {
+	if r.inSysex {
+		r.emit(Sysex(r.sysex))
+		r.inSysex = false
+		r.sysex = nil
+	}
+}
+
+func (r *Reader) feed(b byte) error {
+	switch {
+	case b == byte(SystemReset):
+		r.inSysex = false
+		r.sysex = nil
+		r.status = 0
+		r.data = nil
+		r.emit(SystemReset)
+		return nil
+
+	case b >= 0xF8:
+		r.emit(Realtime(b))
+		return nil
+
+	case b == 0xF0:
+		r.endSysex()
+		r.status = 0
+		r.data = nil
+		r.inSysex = true
+		r.sysex = []byte{}
+		return nil
+
+	case b == 0xF7:
+		r.endSysex()
+		r.status = 0
+		r.data = nil
+		return nil
+
+	case b&0x80 != 0:
+		r.endSysex()
+		r.status = b
+		r.data = nil
+		switch b {
+		case 0xF6:
+			r.status = 0
+			r.emit(TuneRequest{})
+		case 0xF4, 0xF5:
+			r.status = 0
+			r.emit(Undefined(b))
+		}
+		return nil
+	}
+
+	if r.inSysex {
+		r.sysex = append(r.sysex, b)
+		return nil
+	}
+	if r.status == 0 {
+		return nil
+	}
+
+	r.data = append(r.data, b)
+	if len(r.data) < dataLength(r.status) {
+		return nil
+	}
+
+	data := r.data
+	r.data = nil
+
+	switch r.status {
+	case 0xF1:
+		r.emit(QuarterFrame(data[0]))
+	case 0xF2:
+		r.emit(SongPosition(int(data[0]) | int(data[1])<<7))
+	case 0xF3:
+		r.emit(SongSelect(data[0]))
+	default:
+		evt, err := midi.NewMIDIEvent(r.status, data)
+		if err != nil {
+			return err
+		}
+		r.emit(evt)
+		return nil
+	}
+
+	r.status = 0
+	return nil
+}
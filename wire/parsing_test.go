@@ -0,0 +1,108 @@
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/steinarvk/midi"
+)
+
+func readAll(t *testing.T, data []byte) ([]Message, error) {
+	r := NewReader(bytes.NewReader(data))
+	var rv []Message
+	for {
+		m, err := r.Read()
+		if err == io.EOF {
+			return rv, nil
+		}
+		if err != nil {
+			return rv, err
+		}
+		rv = append(rv, m)
+	}
+}
+
+func event(t *testing.T, status byte, data ...byte) midi.MIDIEvent {
+	evt, err := midi.NewMIDIEvent(status, data)
+	if err != nil {
+		t.Fatalf("midi.NewMIDIEvent(%02x, %v) = err: %v", status, data, err)
+	}
+	return evt
+}
+
+func TestRead(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want []Message
+	}{
+		{
+			"channel messages with running status",
+			[]byte{0x90, 0x3c, 0x40, 0x3e, 0x40, 0xc1, 0x05, 0x06},
+			[]Message{event(t, 0x90, 0x3c, 0x40), event(t, 0x90, 0x3e, 0x40), event(t, 0xc1, 0x05), event(t, 0xc1, 0x06)},
+		},
+		{
+			"realtime in the middle of a message",
+			[]byte{0x90, 0xf8, 0x3c, 0xfe, 0x40, 0xfa},
+			[]Message{TimingClock, ActiveSensing, event(t, 0x90, 0x3c, 0x40), Start},
+		},
+		{
+			"sysex",
+			[]byte{0xf0, 0x7e, 0xf8, 0x7f, 0x09, 0x01, 0xf7, 0x3c, 0x40},
+			[]Message{TimingClock, Sysex{0x7e, 0x7f, 0x09, 0x01}},
+		},
+		{
+			"sysex ended by a status byte",
+			[]byte{0xf0, 0x01, 0x02, 0x80, 0x3c, 0x00},
+			[]Message{Sysex{0x01, 0x02}, event(t, 0x80, 0x3c, 0x00)},
+		},
+		{
+			"empty sysex",
+			[]byte{0xf0, 0xf7},
+			[]Message{Sysex{}},
+		},
+		{
+			"system common cancels running status",
+			[]byte{0x90, 0x3c, 0x40, 0xf2, 0x10, 0x02, 0xf1, 0x35, 0xf3, 0x07, 0xf6, 0xf5, 0x3c, 0x40},
+			[]Message{event(t, 0x90, 0x3c, 0x40), SongPosition(0x110), QuarterFrame(0x35), SongSelect(7), TuneRequest{}, Undefined(0xf5)},
+		},
+		{
+			"reset drops partial message and running status",
+			[]byte{0x90, 0x3c, 0xff, 0x40, 0x90, 0x3c, 0x40, 0xf0, 0x01, 0xff, 0x02, 0xf7},
+			[]Message{SystemReset, event(t, 0x90, 0x3c, 0x40), SystemReset},
+		},
+		{
+			"message cut short by status byte",
+			[]byte{0x90, 0x3c, 0xb0, 0x07, 0x64},
+			[]Message{event(t, 0xb0, 0x07, 0x64)},
+		},
+		{
+			"stray data and end of exclusive",
+			[]byte{0x01, 0xf7, 0x02, 0xf9, 0xfd},
+			[]Message{Realtime(0xf9), Realtime(0xfd)},
+		},
+	} {
+		got, err := readAll(t, tc.data)
+		if err != nil {
+			t.Errorf("%s: reading % x: err: %v", tc.name, tc.data, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: reading % x = %v want %v", tc.name, tc.data, got, tc.want)
+		}
+	}
+}
+
+func TestReadUnexpectedEOF(t *testing.T) {
+	for _, data := range [][]byte{
+		{0x90, 0x3c},
+		{0xf0, 0x01},
+		{0xf2, 0x00},
+	} {
+		if _, err := readAll(t, data); err != io.ErrUnexpectedEOF {
+			t.Errorf("reading % x: err = %v want %v", data, err, io.ErrUnexpectedEOF)
+		}
+	}
+}
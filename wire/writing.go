@@ -0,0 +1,251 @@
+package wire
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/steinarvk/midi"
+)
+
+// Encode returns the bytes of a message as sent on the wire, without
+// running status. Besides the message types Read returns, it accepts
+// sysex events from files, whose packets are sent as they are.
+//
+// MIDIEvent has no field for the value of a pitch bend, so a PitchBend
+// event must carry its data bytes in RawData, as events that are parsed
+// or made with midi.NewMIDIEvent do.
+func Encode(m Message) ([]byte, error) {
+	switch v := m.(type) {
+	case midi.MIDIEvent:
+		if v.Channel < 0 || v.Channel > 15 {
+			return nil, fmt.Errorf("%v: channel out of range", v)
+		}
+		data, err := v.EncodeMIDI()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) != 1+dataLength(data[0]) {
+			return nil, fmt.Errorf("%v: want %d data byte(s), got %d", v, dataLength(data[0]), len(data)-1)
+		}
+		if err := checkData(data[1:]); err != nil {
+			return nil, fmt.Errorf("%v: %v", v, err)
+		}
+		return data, nil
+
+	case midi.SysexEvent:
+		if len(v) == 0 {
+			return nil, errors.New("empty SysexEvent")
+		}
+		if v[0] == 0xF7 {
+			return append([]byte(nil), v[1:]...), nil
+		}
+		return append([]byte(nil), v...), nil
+
+	case Sysex:
+		if err := checkData(v); err != nil {
+			return nil, fmt.Errorf("sysex: %v", err)
+		}
+		rv := append([]byte{0xF0}, v...)
+		return append(rv, 0xF7), nil
+
+	case Realtime:
+		if v < 0xF8 {
+			return nil, fmt.Errorf("%02x is not a realtime status byte", byte(v))
+		}
+		return []byte{byte(v)}, nil
+
+	case QuarterFrame:
+		if v > 0x7F {
+			return nil, fmt.Errorf("%v: out of range", v)
+		}
+		return []byte{0xF1, byte(v)}, nil
+
+	case SongPosition:
+		if v < 0 || v > 0x3FFF {
+			return nil, fmt.Errorf("%v: out of range", v)
+		}
+		return []byte{0xF2, byte(v & 0x7F), byte(v >> 7)}, nil
+
+	case SongSelect:
+		if v < 0 || v > 0x7F {
+			return nil, fmt.Errorf("%v: out of range", v)
+		}
+		return []byte{0xF3, byte(v)}, nil
+
+	case TuneRequest:
+		return []byte{0xF6}, nil
+
+	case Undefined:
+		if v != 0xF4 && v != 0xF5 {
+			return nil, fmt.Errorf("%02x is not an undefined status byte", byte(v))
+		}
+		return []byte{byte(v)}, nil
+
+	case midi.MetaEvent, midi.TimeDeltaEvent:
+		return nil, fmt.Errorf("%v cannot be sent on the wire", v)
+	}
+
+	return nil, fmt.Errorf("unknown message type %T", m)
+}
+
+func checkData(data []byte) error {
+	for _, b := range data {
+		if b&0x80 != 0 {
+			return fmt.Errorf("data byte %02x out of range", b)
+		}
+	}
+	return nil
+}
+
+// DefaultActiveSensingInterval is comfortably below the 300ms after which
+// a receiver may assume the connection is lost.
+const DefaultActiveSensingInterval = 270 * time.Millisecond
+
+type Options struct {
+	// RunningStatus leaves out the status byte of a channel message that
+	// is the same as that of the previous one.
+	RunningStatus bool
+
+	// ActiveSensing sends Active Sensing whenever nothing else has been
+	// sent for ActiveSensingInterval (or the default interval, if zero),
+	// from the first message on until the Writer is closed.
+	ActiveSensing         bool
+	ActiveSensingInterval time.Duration
+}
+
+// Writer sends messages on the wire. It is safe for concurrent use.
+type Writer struct {
+	opts Options
+
+	mu     sync.Mutex
+	w      io.Writer
+	status byte
+	err    error
+
+	// sent is signalled on every write, to put off Active Sensing.
+	sent      chan struct{}
+	started   bool
+	closed    chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func NewWriter(w io.Writer, opts Options) *Writer {
+	if opts.ActiveSensingInterval <= 0 {
+		opts.ActiveSensingInterval = DefaultActiveSensingInterval
+	}
+	return &Writer{
+		opts:   opts,
+		w:      w,
+		sent:   make(chan struct{}, 1),
+		closed: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Write sends a message. After a write fails, every later one fails
+// with the same error.
+func (w *Writer) Write(m Message) error {
+	data, err := Encode(m)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+
+	if _, ok := m.(midi.SysexEvent); ok {
+		// Sysex packets, even continuation packets, cancel running status.
+		w.status = 0
+	} else if status := data[0]; status < 0xF0 {
+		if w.opts.RunningStatus && status == w.status {
+			data = data[1:]
+		}
+		w.status = status
+	} else if status < 0xF8 {
+		w.status = 0
+	}
+
+	if err := w.write(data); err != nil {
+		return err
+	}
+
+	if w.opts.ActiveSensing && !w.started {
+		w.started = true
+		go w.sense()
+	}
+	select {
+	case w.sent <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// write writes data with w.mu held.
+func (w *Writer) write(data []byte) error {
+	if _, err := w.w.Write(data); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+// sense sends Active Sensing whenever the line has been quiet too long.
+func (w *Writer) sense() {
+	defer close(w.done)
+
+	timer := time.NewTimer(w.opts.ActiveSensingInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-w.closed:
+			return
+
+		case <-w.sent:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+
+		case <-timer.C:
+			w.mu.Lock()
+			err := w.err
+			if err == nil {
+				err = w.write([]byte{byte(ActiveSensing)})
+			}
+			w.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+		timer.Reset(w.opts.ActiveSensingInterval)
+	}
+}
+
+// Close stops sending Active Sensing. It does not close the underlying
+// writer, and the Writer may not be used afterwards. Closing it again
+// does nothing.
+func (w *Writer) Close() error {
+	w.closeOnce.Do(func() {
+		w.mu.Lock()
+		started := w.started
+		w.started = true
+		w.mu.Unlock()
+
+		close(w.closed)
+		if started {
+			<-w.done
+		}
+	})
+	return nil
+}
@@ -0,0 +1,133 @@
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/steinarvk/midi"
+)
+
+func TestEncode(t *testing.T) {
+	for _, tc := range []struct {
+		m    Message
+		want []byte
+	}{
+		{event(t, 0x92, 0x3c, 0x00), []byte{0x92, 0x3c, 0x00}},
+		{event(t, 0xc1, 0x05), []byte{0xc1, 0x05}},
+		{event(t, 0xe3, 0x00, 0x40), []byte{0xe3, 0x00, 0x40}},
+		{Sysex{0x7e, 0x7f}, []byte{0xf0, 0x7e, 0x7f, 0xf7}},
+		{midi.SysexEvent{0xf0, 0x7e, 0x7f, 0xf7}, []byte{0xf0, 0x7e, 0x7f, 0xf7}},
+		{midi.SysexEvent{0xf7, 0xf8}, []byte{0xf8}},
+		{TimingClock, []byte{0xf8}},
+		{QuarterFrame(0x35), []byte{0xf1, 0x35}},
+		{SongPosition(0x110), []byte{0xf2, 0x10, 0x02}},
+		{SongSelect(7), []byte{0xf3, 0x07}},
+		{TuneRequest{}, []byte{0xf6}},
+	} {
+		got, err := Encode(tc.m)
+		if err != nil {
+			t.Errorf("Encode(%v) = err: %v", tc.m, err)
+			continue
+		}
+		if !bytes.Equal(got, tc.want) {
+			t.Errorf("Encode(%v) = % x want % x", tc.m, got, tc.want)
+		}
+	}
+
+	for _, m := range []Message{
+		Sysex{0x80},
+		Realtime(0xf1),
+		SongPosition(0x4000),
+		midi.MetaEvent{Type: midi.EndOfTrack},
+		midi.TimeDeltaEvent(10),
+		midi.MIDIEvent{Type: midi.NoteOn, Channel: 16, Key: 60},
+		midi.MIDIEvent{Type: midi.NoteOn, Key: 128},
+		midi.MIDIEvent{Type: midi.PitchBend, Channel: 3},
+	} {
+		if got, err := Encode(m); err == nil {
+			t.Errorf("Encode(%v) = % x want error", m, got)
+		}
+	}
+}
+
+func TestWriteRunningStatus(t *testing.T) {
+	messages := []Message{
+		event(t, 0x90, 0x3c, 0x40),
+		TimingClock,
+		event(t, 0x90, 0x3e, 0x40),
+		event(t, 0x90, 0x3c, 0x00),
+		SongSelect(1),
+		event(t, 0x90, 0x3e, 0x00),
+		event(t, 0x80, 0x3e, 0x00),
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, Options{RunningStatus: true})
+	for _, m := range messages {
+		if err := w.Write(m); err != nil {
+			t.Fatalf("Write(%v) = err: %v", m, err)
+		}
+	}
+	w.Close()
+
+	want := []byte{0x90, 0x3c, 0x40, 0xf8, 0x3e, 0x40, 0x3c, 0x00, 0xf3, 0x01, 0x90, 0x3e, 0x00, 0x80, 0x3e, 0x00}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("writing %v = % x want % x", messages, buf.Bytes(), want)
+	}
+
+	got, err := readAll(t, buf.Bytes())
+	if err != nil {
+		t.Fatalf("reading % x: err: %v", buf.Bytes(), err)
+	}
+	if !reflect.DeepEqual(got, messages) {
+		t.Errorf("reading % x = %v want %v", buf.Bytes(), got, messages)
+	}
+}
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(data)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+func TestWriteActiveSensing(t *testing.T) {
+	var buf lockedBuffer
+	w := NewWriter(&buf, Options{ActiveSensing: true, ActiveSensingInterval: time.Millisecond})
+
+	time.Sleep(10 * time.Millisecond)
+	if got := buf.Bytes(); len(got) != 0 {
+		t.Errorf("before the first message, wrote % x", got)
+	}
+
+	if err := w.Write(Start); err != nil {
+		t.Fatalf("Write(Start) = err: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	w.Close()
+	w.Close()
+
+	got := buf.Bytes()
+	if len(got) < 2 || got[0] != byte(Start) || !bytes.Equal(bytes.Trim(got[1:], "\xfe"), nil) {
+		t.Errorf("wrote % x want fa followed by fe bytes", got)
+	}
+
+	after := len(buf.Bytes())
+	time.Sleep(10 * time.Millisecond)
+	if n := len(buf.Bytes()); n != after {
+		t.Errorf("wrote %d byte(s) after Close", n-after)
+	}
+}